@@ -0,0 +1,317 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTooManyAttempts - попытка входа отклонена: действует задержка или блокировка
+var ErrTooManyAttempts = errors.New("слишком много попыток входа")
+
+// Attempts - счётчик неудачных попыток входа по одному ключу (логин или IP)
+type Attempts struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// AttemptStore - хранилище счётчиков. По умолчанию в памяти процесса,
+// для нескольких инстансов можно подключить Redis-реализацию.
+//
+// Incr и Decr должны быть атомарными: проверка задержки и увеличение
+// счётчика выполняются одним шагом, иначе параллельные попытки проходят
+// мимо задержки и теряют инкременты. Расчёт задержки берётся из
+// LockoutPolicy (Expired/Wait/Register), хранилище только обеспечивает
+// атомарность (mutex, Lua-скрипт в Redis и т.п.).
+type AttemptStore interface {
+	// Get возвращает счётчик без изменений (для админки)
+	Get(key string) (Attempts, bool)
+	// Incr засчитывает попытку, если политика p сейчас её разрешает.
+	// Возвращает новое состояние и 0, либо неизменённое состояние
+	// и оставшееся время ожидания.
+	Incr(key string, p LockoutPolicy, now time.Time) (Attempts, time.Duration, error)
+	// Decr возвращает ранее засчитанную попытку (вход оказался удачным)
+	Decr(key string, p LockoutPolicy) error
+	Delete(key string) error
+}
+
+// MemoryAttemptStore - AttemptStore в памяти процесса
+type MemoryAttemptStore struct {
+	mu      sync.Mutex
+	entries map[string]Attempts
+	maxIdle time.Duration
+}
+
+// memoryStorePruneSize - при таком количестве ключей удаляем устаревшие
+const memoryStorePruneSize = 10000
+
+// NewMemoryAttemptStore создаёт хранилище; записи без активности дольше
+// maxIdle удаляются при переполнении.
+func NewMemoryAttemptStore(maxIdle time.Duration) *MemoryAttemptStore {
+	return &MemoryAttemptStore{entries: make(map[string]Attempts), maxIdle: maxIdle}
+}
+
+func (s *MemoryAttemptStore) Get(key string) (Attempts, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.entries[key]
+	return a, ok
+}
+
+func (s *MemoryAttemptStore) Incr(key string, p LockoutPolicy, now time.Time) (Attempts, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.entries[key]
+	if ok && p.Expired(a, now) {
+		a = Attempts{}
+	}
+	if wait := p.Wait(a, now); wait > 0 {
+		return a, wait, nil
+	}
+	if len(s.entries) >= memoryStorePruneSize {
+		s.prune(now)
+	}
+	a = p.Register(a, now)
+	s.entries[key] = a
+	return a, 0, nil
+}
+
+func (s *MemoryAttemptStore) Decr(key string, p LockoutPolicy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	a = p.Unregister(a)
+	if a.Failures == 0 {
+		delete(s.entries, key)
+	} else {
+		s.entries[key] = a
+	}
+	return nil
+}
+
+func (s *MemoryAttemptStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryAttemptStore) prune(now time.Time) {
+	for k, a := range s.entries {
+		if now.After(a.LockedUntil) && now.Sub(a.LastFailure) > s.maxIdle {
+			delete(s.entries, k)
+		}
+	}
+}
+
+// LockoutPolicy - правила задержки и блокировки для одного типа ключа
+type LockoutPolicy struct {
+	FreeAttempts    int           // неудачных попыток без задержки
+	BaseDelay       time.Duration // первая задержка, дальше удваивается
+	MaxDelay        time.Duration // потолок задержки
+	LockoutAfter    int           // после стольких неудач — блокировка
+	LockoutDuration time.Duration // длительность блокировки
+	Window          time.Duration // счётчик сбрасывается после паузы дольше Window
+}
+
+// DefaultUserPolicy - ограничения на один логин
+var DefaultUserPolicy = LockoutPolicy{
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        30 * time.Second,
+	LockoutAfter:    10,
+	LockoutDuration: 15 * time.Minute,
+	Window:          15 * time.Minute,
+}
+
+// DefaultIPPolicy - ограничения на один IP (перебор разных логинов)
+var DefaultIPPolicy = LockoutPolicy{
+	FreeAttempts:    10,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	LockoutAfter:    50,
+	LockoutDuration: 30 * time.Minute,
+	Window:          30 * time.Minute,
+}
+
+// Expired - счётчик устарел: блокировки нет и пауза дольше Window
+func (p LockoutPolicy) Expired(a Attempts, now time.Time) bool {
+	return now.After(a.LockedUntil) && now.Sub(a.LastFailure) > p.Window
+}
+
+// Wait - сколько ещё ждать до следующей попытки (0 — можно сейчас)
+func (p LockoutPolicy) Wait(a Attempts, now time.Time) time.Duration {
+	if now.Before(a.LockedUntil) {
+		return a.LockedUntil.Sub(now)
+	}
+	if a.Failures <= p.FreeAttempts {
+		return 0
+	}
+	if w := a.LastFailure.Add(backoff(p, a.Failures)).Sub(now); w > 0 {
+		return w
+	}
+	return 0
+}
+
+// Register засчитывает попытку и при достижении порога ставит блокировку
+func (p LockoutPolicy) Register(a Attempts, now time.Time) Attempts {
+	a.Failures++
+	a.LastFailure = now
+	if p.LockoutAfter > 0 && a.Failures >= p.LockoutAfter {
+		a.LockedUntil = now.Add(p.LockoutDuration)
+	}
+	return a
+}
+
+// Unregister откатывает одну засчитанную попытку
+func (p LockoutPolicy) Unregister(a Attempts) Attempts {
+	if a.Failures > 0 {
+		a.Failures--
+	}
+	if p.LockoutAfter <= 0 || a.Failures < p.LockoutAfter {
+		a.LockedUntil = time.Time{}
+	}
+	return a
+}
+
+// LoginGuard защищает вход от перебора паролей: считает неудачные
+// попытки по логину и по IP, увеличивает задержку экспоненциально
+// и временно блокирует после порога.
+//
+// Попытка засчитывается как неудачная заранее (Attempt), до проверки
+// пароля, а удачный вход её откатывает (Success). Так параллельные
+// запросы видят друг друга и не проходят мимо задержки.
+type LoginGuard struct {
+	store      AttemptStore
+	userPolicy LockoutPolicy
+	ipPolicy   LockoutPolicy
+	now        func() time.Time
+}
+
+// NewLoginGuard создаёт LoginGuard с политиками по умолчанию
+func NewLoginGuard(store AttemptStore) *LoginGuard {
+	return &LoginGuard{
+		store:      store,
+		userPolicy: DefaultUserPolicy,
+		ipPolicy:   DefaultIPPolicy,
+		now:        time.Now,
+	}
+}
+
+// WithPolicies задаёт свои политики (например, в тестах)
+func (g *LoginGuard) WithPolicies(user, ip LockoutPolicy) *LoginGuard {
+	g.userPolicy = user
+	g.ipPolicy = ip
+	return g
+}
+
+// WithClock подменяет источник времени (для тестов)
+func (g *LoginGuard) WithClock(now func() time.Time) *LoginGuard {
+	g.now = now
+	return g
+}
+
+func userKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Attempt проверяет задержку по логину и IP и, если попытка разрешена,
+// сразу засчитывает её как неудачную. При задержке возвращает
+// ErrTooManyAttempts и время ожидания; счётчики при этом не меняются.
+func (g *LoginGuard) Attempt(username, ip string) (time.Duration, error) {
+	now := g.now()
+	uk := userKey(username)
+	_, wait, err := g.store.Incr(uk, g.userPolicy, now)
+	if err != nil {
+		return 0, err
+	}
+	if wait > 0 {
+		return wait, ErrTooManyAttempts
+	}
+	_, wait, err = g.store.Incr(ipKey(ip), g.ipPolicy, now)
+	if err == nil && wait <= 0 {
+		return 0, nil
+	}
+	// Логин уже засчитан — откатываем, раз попытка не состоялась
+	if derr := g.store.Decr(uk, g.userPolicy); derr != nil && err == nil {
+		err = derr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return wait, ErrTooManyAttempts
+}
+
+// Success откатывает попытку после успешного входа: счётчик логина
+// сбрасывается, у IP снимается только эта попытка, чтобы один удачный
+// вход не обнулял перебор чужих логинов.
+func (g *LoginGuard) Success(username, ip string) error {
+	if err := g.store.Delete(userKey(username)); err != nil {
+		return err
+	}
+	return g.store.Decr(ipKey(ip), g.ipPolicy)
+}
+
+// Release откатывает попытку, которая не дошла до проверки пароля
+// (например, из-за ошибки БД)
+func (g *LoginGuard) Release(username, ip string) error {
+	if err := g.store.Decr(userKey(username), g.userPolicy); err != nil {
+		return err
+	}
+	return g.store.Decr(ipKey(ip), g.ipPolicy)
+}
+
+// Unlock снимает блокировку логина и перечисленных IP
+// (ручная разблокировка администратором)
+func (g *LoginGuard) Unlock(username string, ips ...string) error {
+	if err := g.store.Delete(userKey(username)); err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if err := g.store.Delete(ipKey(ip)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Status возвращает текущий счётчик логина (для админки)
+func (g *LoginGuard) Status(username string) (Attempts, bool) {
+	a, ok := g.store.Get(userKey(username))
+	if !ok || g.userPolicy.Expired(a, g.now()) {
+		return Attempts{}, false
+	}
+	return a, true
+}
+
+// IPWindow - за какой период искать IP неудачных попыток при разблокировке
+func (g *LoginGuard) IPWindow() time.Duration {
+	if g.ipPolicy.LockoutDuration > g.ipPolicy.Window {
+		return g.ipPolicy.LockoutDuration
+	}
+	return g.ipPolicy.Window
+}
+
+// backoff - задержка после n-й неудачи: BaseDelay * 2^(n-FreeAttempts-1), не больше MaxDelay
+func backoff(p LockoutPolicy, failures int) time.Duration {
+	d := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures; i++ {
+		d *= 2
+		if d >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if d > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return d
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testPolicy = LockoutPolicy{
+	FreeAttempts:    2,
+	BaseDelay:       time.Second,
+	MaxDelay:        4 * time.Second,
+	LockoutAfter:    6,
+	LockoutDuration: time.Minute,
+	Window:          time.Minute,
+}
+
+// testGuard - LoginGuard с ручными часами; IP-политика мягкая, чтобы не мешала
+func testGuard() (*LoginGuard, *time.Time) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	ipPolicy := testPolicy
+	ipPolicy.FreeAttempts = 1000
+	ipPolicy.LockoutAfter = 0
+	g := NewLoginGuard(NewMemoryAttemptStore(time.Hour)).
+		WithPolicies(testPolicy, ipPolicy).
+		WithClock(func() time.Time { return now })
+	return g, &now
+}
+
+func TestBackoff(t *testing.T) {
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{6, 4 * time.Second},
+		{20, 4 * time.Second},
+	}
+	for _, tc := range cases {
+		if got := backoff(testPolicy, tc.failures); got != tc.want {
+			t.Errorf("backoff(%d) = %v, want %v", tc.failures, got, tc.want)
+		}
+	}
+}
+
+func TestLoginGuardBackoffGrows(t *testing.T) {
+	g, now := testGuard()
+
+	// Бесплатные попытки проходят без задержки
+	for i := 0; i < testPolicy.FreeAttempts+1; i++ {
+		if _, err := g.Attempt("bob", "10.0.0.1"); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+
+	// Дальше задержка удваивается: 1с, 2с, 4с
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		wait, err := g.Attempt("bob", "10.0.0.1")
+		if !errors.Is(err, ErrTooManyAttempts) || wait != want {
+			t.Fatalf("wait = %v, %v; want %v, ErrTooManyAttempts", wait, err, want)
+		}
+		*now = now.Add(want)
+		if _, err := g.Attempt("bob", "10.0.0.1"); err != nil {
+			t.Fatalf("after %v: %v", want, err)
+		}
+	}
+
+	// Порог достигнут — блокировка на LockoutDuration
+	wait, err := g.Attempt("bob", "10.0.0.1")
+	if !errors.Is(err, ErrTooManyAttempts) || wait != testPolicy.LockoutDuration {
+		t.Fatalf("lockout wait = %v, %v; want %v", wait, err, testPolicy.LockoutDuration)
+	}
+	if a, _ := g.Status("bob"); a.Failures != testPolicy.LockoutAfter {
+		t.Fatalf("failures = %d, want %d (отклонённые попытки не считаются)", a.Failures, testPolicy.LockoutAfter)
+	}
+}
+
+func TestLoginGuardWindowExpiry(t *testing.T) {
+	g, now := testGuard()
+	for i := 0; i < testPolicy.FreeAttempts+1; i++ {
+		g.Attempt("bob", "10.0.0.1")
+	}
+	if _, err := g.Attempt("bob", "10.0.0.1"); err == nil {
+		t.Fatal("expected delay")
+	}
+
+	*now = now.Add(testPolicy.Window + time.Second)
+	if _, found := g.Status("bob"); found {
+		t.Fatal("counter should expire after window")
+	}
+	if _, err := g.Attempt("bob", "10.0.0.1"); err != nil {
+		t.Fatalf("attempt after window: %v", err)
+	}
+	if a, _ := g.Status("bob"); a.Failures != 1 {
+		t.Fatalf("failures = %d, want 1", a.Failures)
+	}
+}
+
+func TestLoginGuardLockoutOutlivesWindow(t *testing.T) {
+	g, now := testGuard()
+	p := testPolicy
+	p.FreeAttempts = 10
+	p.LockoutAfter = 2
+	p.LockoutDuration = 3 * time.Minute
+	g.WithPolicies(p, g.ipPolicy)
+
+	g.Attempt("bob", "10.0.0.1")
+	g.Attempt("bob", "10.0.0.1")
+	*now = now.Add(2 * time.Minute)
+	if _, err := g.Attempt("bob", "10.0.0.1"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("lock must hold past window, got %v", err)
+	}
+	*now = now.Add(2 * time.Minute)
+	if _, err := g.Attempt("bob", "10.0.0.1"); err != nil {
+		t.Fatalf("lock should be over: %v", err)
+	}
+}
+
+func TestLoginGuardSuccessAndUnlock(t *testing.T) {
+	g, _ := testGuard()
+	for i := 0; i < testPolicy.LockoutAfter; i++ {
+		g.Attempt("Bob", "10.0.0.1")
+	}
+	if _, err := g.Attempt("bob", "10.0.0.1"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("expected lockout, got %v", err)
+	}
+
+	if err := g.Unlock(" BOB ", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := g.Status("bob"); found {
+		t.Fatal("unlock must clear user counter")
+	}
+	if _, found := g.store.Get(ipKey("10.0.0.1")); found {
+		t.Fatal("unlock must clear ip counter")
+	}
+
+	// Удачный вход откатывает свою попытку и сбрасывает счётчик логина
+	g.Attempt("bob", "10.0.0.2")
+	g.Attempt("bob", "10.0.0.2")
+	if _, err := g.Attempt("bob", "10.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Success("bob", "10.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := g.Status("bob"); found {
+		t.Fatal("success must clear user counter")
+	}
+	if a, _ := g.store.Get(ipKey("10.0.0.2")); a.Failures != 2 {
+		t.Fatalf("ip failures = %d, want 2", a.Failures)
+	}
+}
+
+func TestLoginGuardIPLock(t *testing.T) {
+	g, _ := testGuard()
+	g.WithPolicies(g.ipPolicy, testPolicy)
+
+	// Перебор разных логинов с одного IP упирается в лимит IP,
+	// а отклонённая по IP попытка не засчитывается логину
+	for i := 0; i < testPolicy.FreeAttempts+1; i++ {
+		if _, err := g.Attempt("user"+string(rune('a'+i)), "10.0.0.9"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := g.Attempt("victim", "10.0.0.9"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("expected ip delay, got %v", err)
+	}
+	if _, found := g.Status("victim"); found {
+		t.Fatal("rejected attempt must not count against the user")
+	}
+}
+
+func TestLoginGuardConcurrentAttempts(t *testing.T) {
+	g, _ := testGuard()
+
+	// Параллельные попытки не проходят мимо задержки: пропускаются
+	// только бесплатные и одна следующая, от которой отсчитывается задержка
+	const n = 50
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	passed := 0
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := g.Attempt("bob", "10.0.0.1"); err == nil {
+				mu.Lock()
+				passed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if passed != testPolicy.FreeAttempts+1 {
+		t.Fatalf("passed = %d, want %d", passed, testPolicy.FreeAttempts+1)
+	}
+	if a, _ := g.Status("bob"); a.Failures != passed {
+		t.Fatalf("failures = %d, want %d", a.Failures, passed)
+	}
+}
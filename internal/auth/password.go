@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"regexp"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordCost - стоимость bcrypt для новых хешей
+const PasswordCost = bcrypt.DefaultCost
+
+// legacySHA256 - формат старых хешей: hex(sha256(password)) без соли
+var legacySHA256 = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// HashPassword возвращает bcrypt хеш пароля (с солью)
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), PasswordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword сверяет пароль с сохранённым хешем.
+// needsRehash = true, если хеш устарел (SHA-256 или bcrypt с меньшей стоимостью)
+// и его нужно перезаписать после успешного входа.
+func CheckPassword(hash, password string) (ok bool, needsRehash bool) {
+	if legacySHA256.MatchString(hash) {
+		sum := sha256.Sum256([]byte(password))
+		ok = subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(hash)) == 1
+		return ok, ok
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return true, err != nil || cost < PasswordCost
+}
+
+// IsPasswordHash - похожа ли строка на хеш, который понимает CheckPassword
+func IsPasswordHash(hash string) bool {
+	if legacySHA256.MatchString(hash) {
+		return true
+	}
+	_, err := bcrypt.Cost([]byte(hash))
+	return err == nil
+}
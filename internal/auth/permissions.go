@@ -0,0 +1,129 @@
+package auth
+
+// Роли пользователей (models.User.Role)
+const (
+	RoleAdmin       = "admin"
+	RoleOperator    = "operator"    // оператор сканера: просмотр и перемещение
+	RoleMechanic    = "mechanic"    // создаёт заявки на детали
+	RoleStorekeeper = "storekeeper" // кладовщик: сборка/выдача заявок, приёмка поставок
+	RoleEngineer    = "engineer"    // проверка заявок на снабжение
+	RoleManager     = "manager"     // одобрение руководителем
+	RoleProcurement = "procurement" // снабжение: назначение и выбор поставщика
+	RoleCommercial  = "commercial"  // коммерческий директор: финальное согласование цены
+)
+
+// Permission - действие над группой маршрутов
+type Permission string
+
+const (
+	PermStockRead    Permission = "stock:read"    // GET /api/item/*, /api/location/*
+	PermStockMove    Permission = "stock:move"    // POST /api/move
+	PermCatalogRead  Permission = "catalog:read"  // GET /api/admin/* (товары, локации, техника, QR)
+	PermCatalogWrite Permission = "catalog:write" // изменение справочников в /api/admin/*
+
+	PermCountRecord Permission = "count:record" // просмотр инвентаризаций и ввод посчитанного
+	PermCountManage Permission = "count:manage" // начало, пересчёт, проведение корректировок
+
+	PermOrdersCreate Permission = "orders:create" // POST /api/mechanic/order
+	PermOrdersRead   Permission = "orders:read"   // GET /api/mechanic/orders, /order/:id, /api/equipment/:id
+	PermOrdersManage Permission = "orders:manage" // статус, QR и выдача заявки
+
+	PermSupplyRead              Permission = "supply:read"
+	PermSupplyRequest           Permission = "supply:request"
+	PermSupplyApproveEngineer   Permission = "supply:approve_engineer"
+	PermSupplyApproveManager    Permission = "supply:approve_manager"
+	PermSupplyAssign            Permission = "supply:assign"
+	PermSupplySelectSupplier    Permission = "supply:select_supplier"
+	PermSupplyApproveCommercial Permission = "supply:approve_commercial"
+	PermSupplyRejectCommercial  Permission = "supply:reject_commercial"
+	PermSupplyReceive           Permission = "supply:receive"
+
+	PermUsersManage Permission = "users:manage" // /api/admin/users (только admin)
+)
+
+// allPermissions - полный список прав (есть у администратора)
+var allPermissions = []Permission{
+	PermStockRead, PermStockMove, PermCatalogRead, PermCatalogWrite,
+	PermCountRecord, PermCountManage,
+	PermOrdersCreate, PermOrdersRead, PermOrdersManage,
+	PermSupplyRead, PermSupplyRequest, PermSupplyApproveEngineer, PermSupplyApproveManager,
+	PermSupplyAssign, PermSupplySelectSupplier, PermSupplyApproveCommercial,
+	PermSupplyRejectCommercial, PermSupplyReceive,
+	PermUsersManage,
+}
+
+// rolePermissions - таблица прав: роль → разрешённые действия.
+// Этапы снабжения соответствуют статусам SupplyRequest:
+// created → approved_by_engineer → approved_by_manager → assigned_to_procurement
+// → supplier_selected → approved_by_commercial → received
+var rolePermissions = map[string][]Permission{
+	RoleOperator: {
+		PermStockRead, PermStockMove, PermCatalogRead,
+		PermCountRecord,
+	},
+	RoleMechanic: {
+		PermStockRead, PermCatalogRead,
+		PermOrdersCreate, PermOrdersRead,
+		PermSupplyRead, PermSupplyRequest,
+	},
+	RoleStorekeeper: {
+		PermStockRead, PermStockMove, PermCatalogRead, PermCatalogWrite,
+		PermCountRecord, PermCountManage,
+		PermOrdersRead, PermOrdersManage,
+		PermSupplyRead, PermSupplyReceive,
+	},
+	RoleEngineer: {
+		PermStockRead, PermCatalogRead, PermOrdersRead,
+		PermSupplyRead, PermSupplyRequest, PermSupplyApproveEngineer,
+	},
+	RoleManager: {
+		PermStockRead, PermCatalogRead, PermOrdersRead,
+		PermSupplyRead, PermSupplyApproveManager,
+	},
+	RoleProcurement: {
+		PermCatalogRead,
+		PermSupplyRead, PermSupplyAssign, PermSupplySelectSupplier,
+	},
+	RoleCommercial: {
+		PermCatalogRead,
+		PermSupplyRead, PermSupplyApproveCommercial, PermSupplyRejectCommercial,
+	},
+}
+
+// Roles возвращает список всех известных ролей
+func Roles() []string {
+	return []string{
+		RoleAdmin, RoleOperator, RoleMechanic, RoleStorekeeper,
+		RoleEngineer, RoleManager, RoleProcurement, RoleCommercial,
+	}
+}
+
+// IsValidRole проверяет, что роль есть в таблице прав
+func IsValidRole(role string) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission - разрешено ли роли действие. Администратору разрешено всё.
+func HasPermission(role string, perm Permission) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionsFor возвращает права роли (для отображения в UI)
+func PermissionsFor(role string) []Permission {
+	if role == RoleAdmin {
+		return allPermissions
+	}
+	return rolePermissions[role]
+}
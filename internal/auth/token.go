@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"QR-GENERATOR/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultTokenTTL - время жизни токена, если JWT_TTL не задан
+const DefaultTokenTTL = 12 * time.Hour
+
+// ErrInvalidToken - токен не прошёл проверку подписи/срока действия
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims - содержимое JWT токена сессии
+type Claims struct {
+	UserID   string `json:"uid"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+var (
+	secretOnce sync.Once
+	secretKey  []byte
+)
+
+// signingKey возвращает HMAC ключ из JWT_SECRET.
+// Если переменная не задана, генерируется случайный ключ (токены не переживут рестарт).
+func signingKey() []byte {
+	secretOnce.Do(func() {
+		if s := os.Getenv("JWT_SECRET"); s != "" {
+			secretKey = []byte(s)
+			return
+		}
+		log.Println("⚠️  JWT_SECRET не задан, используется случайный ключ")
+		secretKey = make([]byte, 32)
+		if _, err := rand.Read(secretKey); err != nil {
+			log.Fatalf("❌ Ошибка генерации JWT ключа: %v", err)
+		}
+	})
+	return secretKey
+}
+
+// tokenTTL читает JWT_TTL (формат time.Duration, например "8h")
+func tokenTTL() time.Duration {
+	if v := os.Getenv("JWT_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("⚠️  Некорректный JWT_TTL=%q, используется %s", v, DefaultTokenTTL)
+	}
+	return DefaultTokenTTL
+}
+
+// TokenExpiry - срок действия токена, выпущенного сейчас
+func TokenExpiry() time.Time {
+	return time.Now().Add(tokenTTL())
+}
+
+// IssueToken выпускает подписанный токен сессии sessionID (jti) для пользователя
+func IssueToken(user models.User, sessionID string, expiresAt time.Time) (string, error) {
+	now := time.Now()
+
+	claims := Claims{
+		UserID:   user.ID,
+		Username: user.Username,
+		Role:     user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(signingKey())
+}
+
+// ParseToken проверяет подпись и срок действия токена
+func ParseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return signingKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid || claims.UserID == "" || claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
@@ -5,6 +5,8 @@ import (
 	"log"
 	"os"
 
+	"QR-GENERATOR/internal/inventory"
+	"QR-GENERATOR/internal/locations"
 	"QR-GENERATOR/internal/models"
 
 	"gorm.io/driver/postgres"
@@ -45,14 +47,21 @@ func InitDB() error {
 	err = DB.AutoMigrate(
 		&models.Location{},
 		&models.Item{},
+		&models.StockBalance{},
+		&models.ItemBarcode{},
 		&models.User{},
+		&models.Session{},
+		&models.AuthEvent{},
 		&models.ItemMovement{},
+		&models.StockAdjustment{},
 		&models.Equipment{},
 		&models.WorkOrder{},
 		&models.WorkOrderItem{},
 		&models.SupplyRequest{},
 		&models.Supplier{},
 		&models.ProcurementTask{},
+		&models.StockCount{},
+		&models.StockCountLine{},
 	)
 
 	if err != nil {
@@ -60,6 +69,16 @@ func InitDB() error {
 		return err
 	}
 
+	// Локации до иерархии становятся ячейками верхнего уровня
+	if err := locations.Backfill(DB); err != nil {
+		log.Printf("⚠️  Ошибка заполнения иерархии локаций: %v", err)
+	}
+
+	// Переносим остатки старых записей (items.quantity на items.location_id) в stock_balances
+	if err := inventory.BackfillBalances(DB); err != nil {
+		log.Printf("⚠️  Ошибка заполнения stock_balances: %v", err)
+	}
+
 	log.Println("✓ Database migrations completed")
 	return nil
 }
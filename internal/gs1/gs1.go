@@ -0,0 +1,296 @@
+// Package gs1 - заводские штрихкоды: проверка и нормализация EAN/UPC (GTIN)
+// и разбор GS1-128 / GS1 DataMatrix по идентификаторам применения (AI):
+// GTIN (01), партия (10), срок годности (17), серийный номер (21) и др.
+package gs1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GS - разделитель полей переменной длины (FNC1 в данных сканера)
+const GS = "\x1d"
+
+var (
+	// ErrMalformed - строка не является данными GS1
+	ErrMalformed = errors.New("gs1: malformed data")
+	// ErrUnknownAI - идентификатор применения не поддерживается
+	ErrUnknownAI = errors.New("gs1: unknown application identifier")
+	// ErrCheckDigit - неверная контрольная цифра GTIN
+	ErrCheckDigit = errors.New("gs1: invalid check digit")
+)
+
+// Идентификаторы применения, которые используются складом
+const (
+	AIGTIN   = "01"
+	AIBatch  = "10"
+	AIExpiry = "17"
+	AISerial = "21"
+)
+
+// ai - длина данных идентификатора: fixed - ровно max символов, иначе до max
+type ai struct {
+	max   int
+	fixed bool
+}
+
+// ais - поддерживаемые идентификаторы (двух- и трёхзначные).
+// Четырёхзначные 31nn–36nn (вес, размеры) разбираются в aiSpec.
+var ais = map[string]ai{
+	"00":  {18, true}, // SSCC
+	"01":  {14, true}, // GTIN
+	"02":  {14, true}, // GTIN вложенных единиц
+	"10":  {20, false},
+	"11":  {6, true}, // дата производства
+	"12":  {6, true},
+	"13":  {6, true}, // дата упаковки
+	"15":  {6, true}, // годен до (качество)
+	"16":  {6, true},
+	"17":  {6, true}, // срок годности
+	"20":  {2, true},
+	"21":  {20, false},
+	"22":  {20, false},
+	"30":  {8, false}, // количество
+	"37":  {8, false},
+	"240": {30, false}, "241": {30, false}, "250": {30, false}, "251": {30, false},
+	"400": {30, false}, "401": {30, false}, "403": {30, false},
+	"410": {13, true}, "411": {13, true}, "412": {13, true}, "413": {13, true}, "414": {13, true},
+	"90": {30, false},
+	"91": {90, false}, "92": {90, false}, "93": {90, false}, "94": {90, false},
+	"95": {90, false}, "96": {90, false}, "97": {90, false}, "98": {90, false}, "99": {90, false},
+}
+
+// aiSpec находит идентификатор в начале s
+func aiSpec(s string) (string, ai, bool) {
+	if len(s) >= 4 && s[0] == '3' && s[1] >= '1' && s[1] <= '6' {
+		return s[:4], ai{6, true}, true
+	}
+	for _, n := range []int{2, 3} {
+		if len(s) >= n {
+			if spec, ok := ais[s[:n]]; ok {
+				return s[:n], spec, true
+			}
+		}
+	}
+	return "", ai{}, false
+}
+
+// Data - разобранный штрихкод GS1
+type Data struct {
+	GTIN   string            `json:"gtin,omitempty"`
+	Batch  string            `json:"batch,omitempty"`
+	Expiry *time.Time        `json:"expiry,omitempty"`
+	Serial string            `json:"serial,omitempty"`
+	Values map[string]string `json:"values"` // все поля: AI -> значение
+}
+
+// Parse разбирает данные GS1 в формате для человека "(01)04006381333931(10)ABC"
+// или в виде со сканера: необязательный префикс символики (]C1, ]d2, ]Q3, ]e0)
+// и поля переменной длины, разделённые GS.
+func Parse(s string) (Data, error) {
+	s = strings.TrimSpace(s)
+	var values map[string]string
+	var err error
+	if strings.HasPrefix(s, "(") {
+		values, err = parseBracketed(s)
+	} else {
+		values, err = parseRaw(s)
+	}
+	if err != nil {
+		return Data{}, err
+	}
+
+	d := Data{Values: values, Batch: values[AIBatch], Serial: values[AISerial]}
+	if v, ok := values[AIGTIN]; ok {
+		if !ValidCheckDigit(v) {
+			return Data{}, fmt.Errorf("%w: %s", ErrCheckDigit, v)
+		}
+		d.GTIN = v
+	}
+	if v, ok := values[AIExpiry]; ok {
+		t, err := parseDate(v)
+		if err != nil {
+			return Data{}, err
+		}
+		d.Expiry = &t
+	}
+	return d, nil
+}
+
+func parseBracketed(s string) (map[string]string, error) {
+	values := make(map[string]string)
+	for s != "" {
+		if s[0] != '(' {
+			return nil, ErrMalformed
+		}
+		end := strings.IndexByte(s, ')')
+		if end < 0 {
+			return nil, ErrMalformed
+		}
+		id := s[1:end]
+		s = s[end+1:]
+		next := strings.IndexByte(s, '(')
+		if next < 0 {
+			next = len(s)
+		}
+		value := s[:next]
+		s = s[next:]
+
+		code, spec, ok := aiSpec(id)
+		if !ok || code != id {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownAI, id)
+		}
+		if err := checkLength(id, value, spec); err != nil {
+			return nil, err
+		}
+		values[id] = value
+	}
+	if len(values) == 0 {
+		return nil, ErrMalformed
+	}
+	return values, nil
+}
+
+func parseRaw(s string) (map[string]string, error) {
+	for _, prefix := range []string{"]C1", "]d2", "]Q3", "]e0"} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	s = strings.TrimPrefix(s, GS)
+
+	values := make(map[string]string)
+	for s != "" {
+		id, spec, ok := aiSpec(s)
+		if !ok {
+			return nil, fmt.Errorf("%w: %.4s", ErrUnknownAI, s)
+		}
+		s = s[len(id):]
+
+		var value string
+		if spec.fixed {
+			if len(s) < spec.max {
+				return nil, fmt.Errorf("%w: короткое поле %s", ErrMalformed, id)
+			}
+			value, s = s[:spec.max], s[spec.max:]
+		} else {
+			end := strings.Index(s, GS)
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = s[:end], s[end:]
+		}
+		s = strings.TrimPrefix(s, GS)
+
+		if err := checkLength(id, value, spec); err != nil {
+			return nil, err
+		}
+		values[id] = value
+	}
+	if len(values) == 0 {
+		return nil, ErrMalformed
+	}
+	return values, nil
+}
+
+func checkLength(id, value string, spec ai) error {
+	if value == "" || len(value) > spec.max || (spec.fixed && len(value) != spec.max) {
+		return fmt.Errorf("%w: длина поля %s", ErrMalformed, id)
+	}
+	return nil
+}
+
+// parseDate - дата YYMMDD; день 00 означает последний день месяца.
+// Год берётся в 2000–2099. Несуществующие даты (260231) отклоняются,
+// а не переносятся на следующий месяц.
+func parseDate(v string) (time.Time, error) {
+	if !isDigits(v) || len(v) != 6 {
+		return time.Time{}, fmt.Errorf("%w: дата %s", ErrMalformed, v)
+	}
+	year := 2000 + int(v[0]-'0')*10 + int(v[1]-'0')
+	month := time.Month(int(v[2]-'0')*10 + int(v[3]-'0'))
+	day := int(v[4]-'0')*10 + int(v[5]-'0')
+	if month < 1 || month > 12 {
+		return time.Time{}, fmt.Errorf("%w: дата %s", ErrMalformed, v)
+	}
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+	if day == 0 {
+		return last, nil
+	}
+	if day > last.Day() {
+		return time.Time{}, fmt.Errorf("%w: дата %s", ErrMalformed, v)
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), nil
+}
+
+// ValidCheckDigit проверяет контрольную цифру GTIN-8/12/13/14
+func ValidCheckDigit(code string) bool {
+	if !isDigits(code) || len(code) < 8 {
+		return false
+	}
+	sum := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		d := int(code[i] - '0')
+		if (len(code)-2-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10-sum%10)%10 == int(code[len(code)-1]-'0')
+}
+
+// NormalizeGTIN приводит EAN-8, UPC-A (12), EAN-13 и GTIN-14 к GTIN-14,
+// чтобы один товар находился по любому из этих представлений
+func NormalizeGTIN(code string) (string, bool) {
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return "", false
+	}
+	if !ValidCheckDigit(code) {
+		return "", false
+	}
+	return strings.Repeat("0", 14-len(code)) + code, true
+}
+
+// Scan разбирает отсканированный код: EAN/UPC даёт только GTIN-14, данные
+// GS1 - все поля. ok=false - код не GS1 (внутренний код, Code 128 поставщика).
+// EAN проверяется первым: "4006381333931" синтаксически тоже GS1 (AI 400).
+func Scan(code string) (Data, bool) {
+	d, err := ScanCode(code)
+	return d, err == nil
+}
+
+// ScanCode - Scan с причиной отказа. Цифровой код длины EAN/UPC/GTIN-14
+// с неверной контрольной цифрой - ErrCheckDigit, а не данные AI 400.
+func ScanCode(code string) (Data, error) {
+	code = strings.TrimSpace(code)
+	if gtin, ok := NormalizeGTIN(code); ok {
+		return Data{GTIN: gtin, Values: map[string]string{AIGTIN: gtin}}, nil
+	}
+	switch len(code) {
+	case 8, 12, 13, 14:
+		if isDigits(code) {
+			return Data{}, fmt.Errorf("%w: %s", ErrCheckDigit, code)
+		}
+	}
+	return Parse(code)
+}
+
+// Key - ключ поиска штрихкода: GTIN-14 для EAN/UPC и данных GS1 с AI 01,
+// иначе сама строка. kind - "gtin" или "other".
+func Key(code string) (key, kind string) {
+	if d, ok := Scan(code); ok && d.GTIN != "" {
+		return d.GTIN, "gtin"
+	}
+	return strings.TrimSpace(code), "other"
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
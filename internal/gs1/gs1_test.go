@@ -0,0 +1,159 @@
+package gs1
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNormalizeGTIN(t *testing.T) {
+	cases := []struct {
+		name, code, want string
+		ok               bool
+	}{
+		{"EAN-8", "96385074", "00000096385074", true},
+		{"UPC-A", "036000291452", "00036000291452", true},
+		{"EAN-13", "4006381333931", "04006381333931", true},
+		{"GTIN-14", "10012345678902", "10012345678902", true},
+		{"EAN-13 bad check digit", "4006381333932", "", false},
+		{"UPC-A bad check digit", "036000291453", "", false},
+		{"wrong length", "123456789", "", false},
+		{"letters", "40063813339A1", "", false},
+		{"empty", "", "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := NormalizeGTIN(tc.code)
+			if got != tc.want || ok != tc.ok {
+				t.Fatalf("NormalizeGTIN(%q) = %q, %v; want %q, %v", tc.code, got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func date(y int, m time.Month, d int) *time.Time {
+	t := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	return &t
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name   string
+		in     string
+		gtin   string
+		batch  string
+		serial string
+		expiry *time.Time
+		values map[string]string
+	}{
+		{
+			name:   "bracketed",
+			in:     "(01)04006381333931(10)L-77(17)260315",
+			gtin:   "04006381333931",
+			batch:  "L-77",
+			expiry: date(2026, time.March, 15),
+		},
+		{
+			name:   "raw with symbology prefix and GS",
+			in:     "]C1010400638133393110L-77\x1d17260315",
+			gtin:   "04006381333931",
+			batch:  "L-77",
+			expiry: date(2026, time.March, 15),
+		},
+		{
+			name:   "raw variable field last, no GS",
+			in:     "]d20104006381333931172603002112345",
+			gtin:   "04006381333931",
+			serial: "12345",
+			expiry: date(2026, time.March, 31),
+		},
+		{
+			name:   "day 00 in february of leap year",
+			in:     "(17)280200",
+			expiry: date(2028, time.February, 29),
+		},
+		{
+			name:   "four-digit AI and quantity",
+			in:     "(3103)001250(30)12",
+			values: map[string]string{"3103": "001250", "30": "12"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := Parse(tc.in)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tc.in, err)
+			}
+			if d.GTIN != tc.gtin || d.Batch != tc.batch || d.Serial != tc.serial {
+				t.Fatalf("Parse(%q) = gtin %q batch %q serial %q", tc.in, d.GTIN, d.Batch, d.Serial)
+			}
+			if (d.Expiry == nil) != (tc.expiry == nil) || (d.Expiry != nil && !d.Expiry.Equal(*tc.expiry)) {
+				t.Fatalf("Parse(%q) expiry = %v, want %v", tc.in, d.Expiry, tc.expiry)
+			}
+			for ai, want := range tc.values {
+				if got := d.Values[ai]; got != want {
+					t.Fatalf("Parse(%q) values[%s] = %q, want %q", tc.in, ai, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		err  error
+	}{
+		{"bad check digit", "(01)04006381333932", ErrCheckDigit},
+		{"unknown AI bracketed", "(99999)X", ErrUnknownAI},
+		{"unknown AI raw", "]C1ZZ123", ErrUnknownAI},
+		{"short fixed field", "]C10104006381", ErrMalformed},
+		{"fixed field wrong length", "(17)2603", ErrMalformed},
+		{"variable field too long", "(10)ABCDEFGHIJKLMNOPQRSTU", ErrMalformed},
+		{"empty value", "(10)", ErrMalformed},
+		{"unclosed bracket", "(01", ErrMalformed},
+		{"empty", "", ErrMalformed},
+		{"february 31", "(17)260231", ErrMalformed},
+		{"april 31", "(17)260431", ErrMalformed},
+		{"february 29 non-leap", "(17)270229", ErrMalformed},
+		{"month 13", "(17)261301", ErrMalformed},
+		{"non-digit date", "(17)26A301", ErrMalformed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Parse(tc.in); !errors.Is(err, tc.err) {
+				t.Fatalf("Parse(%q) error = %v, want %v", tc.in, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestScanAndKey(t *testing.T) {
+	// EAN-13 распознаётся как GTIN, а не как AI 400
+	d, ok := Scan(" 4006381333931 ")
+	if !ok || d.GTIN != "04006381333931" {
+		t.Fatalf("Scan(EAN-13) = %+v, %v", d, ok)
+	}
+
+	for _, bad := range []string{"4006381333932", "(01)04006381333932", "(17)260231", "(99999)X"} {
+		if _, err := ScanCode(bad); err == nil {
+			t.Errorf("ScanCode(%q) accepted a corrupt code", bad)
+		}
+	}
+	if _, err := ScanCode("4006381333932"); !errors.Is(err, ErrCheckDigit) {
+		t.Errorf("ScanCode(bad EAN-13) error = %v, want ErrCheckDigit", err)
+	}
+
+	cases := []struct{ in, key, kind string }{
+		{"036000291452", "00036000291452", "gtin"},
+		{"(01)00036000291452(10)X1", "00036000291452", "gtin"},
+		{"SUP-000123", "SUP-000123", "other"},
+		{"4006381333932", "4006381333932", "other"},
+	}
+	for _, tc := range cases {
+		if key, kind := Key(tc.in); key != tc.key || kind != tc.kind {
+			t.Errorf("Key(%q) = %q, %q; want %q, %q", tc.in, key, kind, tc.key, tc.kind)
+		}
+	}
+}
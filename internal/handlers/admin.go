@@ -1,19 +1,22 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"QR-GENERATOR/internal/database"
+	"QR-GENERATOR/internal/inventory"
 	"QR-GENERATOR/internal/models"
+	"QR-GENERATOR/internal/qr"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	qrcode "github.com/skip2/go-qrcode"
 	"gorm.io/gorm"
 )
 
@@ -23,17 +26,20 @@ import (
 
 // CreateItemRequest - поля для создания товара
 type CreateItemRequest struct {
-	Name           string `json:"name" binding:"required"`
-	SKU            string `json:"sku" binding:"required"`
-	Description    string `json:"description"`
-	Quantity       int    `json:"quantity"`
-	Unit           string `json:"unit"` // шт, кг, м, л
-	Category       string `json:"category"`
-	PartNumber     string `json:"part_number"`
-	BatchNumber    string `json:"batch_number"`
-	BatchQuantity  int    `json:"batch_quantity"`
-	BatchArrivedAt string `json:"batch_arrived_at"` // ISO8601
-	LocationID     string `json:"location_id"`
+	Name           string  `json:"name" binding:"required"`
+	SKU            string  `json:"sku" binding:"required"`
+	Description    string  `json:"description"`
+	Quantity       int     `json:"quantity"`
+	Unit           string  `json:"unit"` // шт, кг, м, л
+	Category       string  `json:"category"`
+	Weight         float64 `json:"weight" binding:"min=0"` // кг на единицу
+	Volume         float64 `json:"volume" binding:"min=0"` // л на единицу
+	PartNumber     string  `json:"part_number"`
+	BatchNumber    string  `json:"batch_number"`
+	BatchQuantity  int     `json:"batch_quantity"`
+	BatchArrivedAt string  `json:"batch_arrived_at"` // ISO8601
+	LocationID     string  `json:"location_id"`
+	Version        int     `json:"version"` // при обновлении: версия, которую видел клиент
 }
 
 // AdminCreateItem POST /api/admin/item
@@ -45,6 +51,9 @@ func AdminCreateItem(c *gin.Context) {
 	}
 
 	db := database.GetDB()
+	if rejectNonBin(c, db, req.LocationID) {
+		return
+	}
 
 	// Парсим время приезда партии
 	var arrivedAt *time.Time
@@ -64,9 +73,10 @@ func AdminCreateItem(c *gin.Context) {
 		Name:           req.Name,
 		SKU:            req.SKU,
 		Description:    req.Description,
-		Quantity:       req.Quantity,
 		Unit:           req.Unit,
 		Category:       req.Category,
+		Weight:         req.Weight,
+		Volume:         req.Volume,
 		PartNumber:     req.PartNumber,
 		BatchNumber:    req.BatchNumber,
 		BatchQuantity:  req.BatchQuantity,
@@ -76,19 +86,26 @@ func AdminCreateItem(c *gin.Context) {
 		UpdatedAt:      time.Now(),
 	}
 
-	if err := db.Create(&item).Error; err != nil {
+	// Товар и начальный остаток на его локации создаются вместе
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+		if req.Quantity > 0 {
+			return inventory.AddStock(tx, item.ID, item.LocationID, req.Quantity)
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
-
-	// Генерируем QR сразу при создании
-	qrPath := fmt.Sprintf("qrcodes/item_%s.png", item.ID)
-	_ = qrcode.WriteFile("ITEM:"+item.ID, qrcode.High, 256, qrPath)
+	item.Quantity = req.Quantity
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"item":    item,
-		"qr_url":  "/qrcodes/item_" + item.ID + ".png",
+		"qr_url":  qrImageURL(qr.TypeItem, item.ID),
 	})
 }
 
@@ -96,26 +113,33 @@ func AdminCreateItem(c *gin.Context) {
 func AdminGetItems(c *gin.Context) {
 	db := database.GetDB()
 
-	search := c.Query("search")
-	category := c.Query("category")
+	var items []models.Item
+	if err := filterItems(db.Preload("Location").Preload("Barcodes"), c).Order("created_at DESC").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
 
-	query := db.Preload("Location")
+	c.JSON(http.StatusOK, gin.H{"success": true, "items": items})
+}
 
-	if search != "" {
+// filterItems применяет фильтры списка товаров из query: search, category,
+// location (есть остаток в локации или во вложенных в неё, например в зоне)
+func filterItems(query *gorm.DB, c *gin.Context) *gorm.DB {
+	if search := c.Query("search"); search != "" {
 		like := "%" + strings.ToLower(search) + "%"
 		query = query.Where("LOWER(name) LIKE ? OR LOWER(sku) LIKE ? OR LOWER(part_number) LIKE ?", like, like, like)
 	}
-	if category != "" {
+	if category := c.Query("category"); category != "" {
 		query = query.Where("category = ?", category)
 	}
-
-	var items []models.Item
-	if err := query.Order("created_at DESC").Find(&items).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
-		return
+	if location := c.Query("location"); location != "" {
+		query = query.Where(`items.id IN (
+			SELECT sb.item_id FROM stock_balances sb
+			JOIN locations l ON l.id = sb.location_id
+			JOIN locations root ON root.id = ?
+			WHERE sb.quantity > 0 AND LEFT(l.path, LENGTH(root.path)) = root.path)`, location)
 	}
-
-	c.JSON(http.StatusOK, gin.H{"success": true, "items": items})
+	return query
 }
 
 // AdminUpdateItem PUT /api/admin/item/:id
@@ -135,19 +159,69 @@ func AdminUpdateItem(c *gin.Context) {
 		return
 	}
 
+	// Карточку редактировали по устаревшим данным
+	if req.Version > 0 && req.Version != item.Version {
+		respondConcurrentModification(c)
+		return
+	}
+	if req.LocationID != item.LocationID && rejectNonBin(c, db, req.LocationID) {
+		return
+	}
+
 	item.Name = req.Name
 	item.SKU = req.SKU
 	item.Description = req.Description
-	item.Quantity = req.Quantity
 	item.Unit = req.Unit
 	item.Category = req.Category
+	item.Weight = req.Weight
+	item.Volume = req.Volume
 	item.PartNumber = req.PartNumber
 	item.BatchNumber = req.BatchNumber
 	item.BatchQuantity = req.BatchQuantity
-	item.LocationID = req.LocationID
 	item.UpdatedAt = time.Now()
 
-	if err := db.Save(&item).Error; err != nil {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := inventory.LockItem(tx, &item); err != nil {
+			return err
+		}
+
+		// Смена основной локации переносит остаток со старой основной локации
+		if req.LocationID != item.LocationID {
+			moved, err := inventory.Balance(tx, item.ID, item.LocationID)
+			if err != nil {
+				return err
+			}
+			if moved > 0 {
+				if err := inventory.Move(tx, item.ID, item.LocationID, req.LocationID, moved); err != nil {
+					return err
+				}
+			}
+			item.LocationID = req.LocationID
+		}
+
+		// Изменение общего количества - корректировка остатка основной локации
+		if delta := req.Quantity - item.Quantity; delta > 0 {
+			if err := inventory.AddStock(tx, item.ID, item.LocationID, delta); err != nil {
+				return err
+			}
+		} else if delta < 0 {
+			if err := inventory.RemoveStock(tx, item.ID, item.LocationID, -delta); err != nil {
+				return err
+			}
+		}
+		item.Quantity = req.Quantity
+
+		return tx.Omit("quantity").Save(&item).Error
+	})
+	if errors.Is(err, inventory.ErrInsufficientStock) {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Нельзя уменьшить количество: на основной локации меньше товара"})
+		return
+	}
+	if errors.Is(err, inventory.ErrConcurrentModification) {
+		respondConcurrentModification(c)
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
@@ -160,30 +234,107 @@ func AdminDeleteItem(c *gin.Context) {
 	id := c.Param("id")
 	db := database.GetDB()
 
-	if err := db.Delete(&models.Item{}, "id = ?", id).Error; err != nil {
+	// Штрихкоды освобождаются, чтобы их можно было привязать к новой карточке
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Item{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Where("item_id = ?", id).Delete(&models.ItemBarcode{}).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
+	qr.Invalidate(qr.TypeItem, id)
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
-// AdminGetItemQR GET /api/admin/item/:id/qr — генерирует и отдаёт QR PNG
+// AdminGetItemQR GET /api/admin/item/:id/qr — генерирует и отдаёт QR
+// Параметры изображения: ?size=&level=&margin=&fg=&bg=&format=png|svg
 func AdminGetItemQR(c *gin.Context) {
 	id := c.Param("id")
+	serveEntityQR(c, qr.TypeItem, id, "qr_"+id)
+}
 
-	qrPath := fmt.Sprintf("qrcodes/item_%s.png", id)
+// serveEntityQR отдаёт QR объекта из кэша изображений (qr.Image).
+// Удалённый объект - 404, поэтому его этикетка больше не раздаётся.
+// ETag меняется вместе с содержимым этикетки и параметрами, браузер
+// перепроверяет изображение при каждом показе (304 без рендеринга).
+// format=zpl отдаёт готовую ZPL этикетку для термопринтера.
+func serveEntityQR(c *gin.Context, t qr.Type, id, downloadName string) {
+	if strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Некорректный ID"})
+		return
+	}
 
-	// Генерируем если не существует
-	if _, err := os.Stat(qrPath); os.IsNotExist(err) {
-		if err := qrcode.WriteFile("ITEM:"+id, qrcode.High, 256, qrPath); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Ошибка генерации QR"})
+	if c.Query("format") == labelFormatZPL {
+		var req LabelsRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
 			return
 		}
+		req.Type = labelTypes[t]
+		req.IDs = []string{id}
+		req.All = false
+		serveLabels(c, req)
+		return
+	}
+
+	opts, err := qr.ParseOptions(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if _, err := qrResolvers[t](database.GetDB(), id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Объект не найден"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		}
+		return
 	}
 
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=qr_%s.png", id))
-	c.File(qrPath)
+	key, err := qr.ImageKey(t, id, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Ошибка генерации QR"})
+		return
+	}
+	etag := `"` + key + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	if match := c.GetHeader("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	data, _, err := qr.Image(t, id, opts)
+	if errors.Is(err, qr.ErrInvalidOptions) {
+		// Например, size меньше числа модулей кода с длинным содержимым
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Ошибка генерации QR"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s%s", downloadName, opts.Ext()))
+	c.Data(http.StatusOK, opts.ContentType(), data)
+}
+
+// qrImagePaths - адрес изображения QR в API для типа объекта
+var qrImagePaths = map[qr.Type]string{
+	qr.TypeItem:      "/api/admin/item/%s/qr",
+	qr.TypeLocation:  "/api/admin/location/%s/qr",
+	qr.TypeWorkOrder: "/api/mechanic/order/%s/qr",
+	qr.TypeEquipment: "/api/admin/equipment/%s/qr",
+}
+
+// qrImageURL - адрес изображения QR объекта (для ответов API)
+func qrImageURL(t qr.Type, id string) string {
+	return fmt.Sprintf(qrImagePaths[t], url.PathEscape(id))
 }
 
 // AdminUploadInvoicePhoto POST /api/admin/item/:id/photo
@@ -223,79 +374,6 @@ func AdminUploadInvoicePhoto(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "photo_url": item.InvoicePhoto})
 }
 
-// ============================================================================
-// LOCATIONS
-// ============================================================================
-
-type CreateLocationRequest struct {
-	Code        string `json:"code" binding:"required"`
-	Description string `json:"description"`
-	Row         string `json:"row"`
-	Section     string `json:"section"`
-	Shelf       string `json:"shelf"`
-}
-
-// AdminGetLocations GET /api/admin/locations
-func AdminGetLocations(c *gin.Context) {
-	db := database.GetDB()
-	var locations []models.Location
-	db.Order("code ASC").Find(&locations)
-	c.JSON(http.StatusOK, gin.H{"success": true, "locations": locations})
-}
-
-// AdminCreateLocation POST /api/admin/location
-func AdminCreateLocation(c *gin.Context) {
-	var req CreateLocationRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
-		return
-	}
-
-	db := database.GetDB()
-
-	loc := models.Location{
-		ID:          "loc_" + uuid.New().String()[:8],
-		Code:        req.Code,
-		Description: req.Description,
-		Row:         req.Row,
-		Section:     req.Section,
-		Shelf:       req.Shelf,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}
-
-	if err := db.Create(&loc).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
-		return
-	}
-
-	// Генерируем QR для локации
-	qrPath := fmt.Sprintf("qrcodes/loc_%s.png", loc.ID)
-	_ = qrcode.WriteFile("LOC:"+loc.ID, qrcode.High, 256, qrPath)
-
-	c.JSON(http.StatusOK, gin.H{
-		"success":  true,
-		"location": loc,
-		"qr_url":   "/qrcodes/loc_" + loc.ID + ".png",
-	})
-}
-
-// AdminGetLocationQR GET /api/admin/location/:id/qr
-func AdminGetLocationQR(c *gin.Context) {
-	id := c.Param("id")
-	qrPath := fmt.Sprintf("qrcodes/loc_%s.png", id)
-
-	if _, err := os.Stat(qrPath); os.IsNotExist(err) {
-		if err := qrcode.WriteFile("LOC:"+id, qrcode.High, 256, qrPath); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Ошибка генерации QR"})
-			return
-		}
-	}
-
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=qr_loc_%s.png", id))
-	c.File(qrPath)
-}
-
 // ============================================================================
 // CATEGORIES
 // ============================================================================
@@ -322,26 +400,21 @@ func UpdateOrderStatus(c *gin.Context) {
 	c.JSON(200, gin.H{"success": true})
 }
 
-// GenerateOrderQR POST /api/mechanic/order/:id/qr
+// GenerateOrderQR POST /api/mechanic/order/:id/qr — адрес QR заявки
+// (изображение рисуется при первом запросе GET и кэшируется)
 func GenerateOrderQR(c *gin.Context) {
 	id := c.Param("id")
-	qrPath := fmt.Sprintf("qrcodes/order_%s.png", id)
-	qrcode.WriteFile("WO:"+id, qrcode.High, 256, qrPath)
-	c.JSON(200, gin.H{"success": true, "qr_url": "/qrcodes/order_" + id + ".png"})
+	var order models.WorkOrder
+	if err := database.GetDB().Select("id").First(&order, "id = ?", id).Error; err != nil {
+		c.JSON(404, gin.H{"success": false, "error": "Заявка не найдена"})
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "qr_url": qrImageURL(qr.TypeWorkOrder, id)})
 }
 
-// IssueOrder POST /api/mechanic/order/:id/issue — списывает остатки
-func IssueOrder(c *gin.Context) {
+// GetOrderQR GET /api/mechanic/order/:id/qr — изображение QR заявки
+// Параметры изображения — как у AdminGetItemQR
+func GetOrderQR(c *gin.Context) {
 	id := c.Param("id")
-	db := database.GetDB()
-	var order models.WorkOrder
-	db.Preload("Items").First(&order, "id = ?", id)
-	for _, item := range order.Items {
-		if item.ItemID != "" {
-			db.Model(&models.Item{}).Where("id = ?", item.ItemID).
-				UpdateColumn("quantity", gorm.Expr("quantity - ?", item.Quantity))
-		}
-	}
-	db.Model(&models.WorkOrder{}).Where("id = ?", id).Update("status", "issued")
-	c.JSON(200, gin.H{"success": true})
+	serveEntityQR(c, qr.TypeWorkOrder, id, "qr_order_"+id)
 }
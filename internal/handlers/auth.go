@@ -1,11 +1,17 @@
 package handlers
 
 import (
-	"crypto/sha256"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
+	"QR-GENERATOR/internal/auth"
 	"QR-GENERATOR/internal/database"
+	"QR-GENERATOR/internal/middleware"
 	"QR-GENERATOR/internal/models"
 
 	"github.com/gin-gonic/gin"
@@ -20,20 +26,54 @@ type LoginRequest struct {
 
 // LoginResponse - ответ при успешном входе
 type LoginResponse struct {
-	Success  bool   `json:"success"`
-	Message  string `json:"message"`
-	UserID   string `json:"user_id,omitempty"`
-	Username string `json:"username,omitempty"`
-	Role     string `json:"role,omitempty"`
-	Token    string `json:"token,omitempty"`
-	Error    string `json:"error,omitempty"`
+	Success            bool       `json:"success"`
+	Message            string     `json:"message"`
+	UserID             string     `json:"user_id,omitempty"`
+	Username           string     `json:"username,omitempty"`
+	Role               string     `json:"role,omitempty"`
+	Token              string     `json:"token,omitempty"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	MustChangePassword bool       `json:"must_change_password,omitempty"` // токен годится только для смены пароля
+	Error              string     `json:"error,omitempty"`
 }
 
-// hashPassword - простая хеширование пароля (SHA256)
-// ⚠️ В production используйте bcrypt!
-func hashPassword(password string) string {
-	hash := sha256.Sum256([]byte(password))
-	return fmt.Sprintf("%x", hash)
+// errInvalidCredentials - единое сообщение для неизвестного логина и неверного пароля
+const errInvalidCredentials = "Неверный логин или пароль"
+
+// dummyPasswordHash - bcrypt хеш для сравнения, когда пользователь не найден
+var dummyPasswordHash, _ = auth.HashPassword("dummy-password-for-timing")
+
+// loginGuard - счётчик неудачных входов (по умолчанию в памяти процесса)
+var loginGuard = auth.NewLoginGuard(auth.NewMemoryAttemptStore(time.Hour))
+
+// SetLoginGuard подменяет защиту от перебора (например, хранилищем в Redis)
+func SetLoginGuard(g *auth.LoginGuard) {
+	loginGuard = g
+}
+
+// currentUserID - ID пользователя из сессии (заполняет middleware.AuthRequired).
+// Только это значение используется как автор действий в аудите.
+func currentUserID(c *gin.Context) string {
+	return c.GetString(middleware.ContextUserID)
+}
+
+// currentRole - роль пользователя из сессии
+func currentRole(c *gin.Context) string {
+	return c.GetString(middleware.ContextRole)
+}
+
+// rejectForeignActor отвечает 403, если клиент прислал ID пользователя,
+// отличный от пользователя сессии (попытка действовать от чужого имени).
+// Пустое значение допускается — автор всё равно берётся из сессии.
+func rejectForeignActor(c *gin.Context, claimedUserID string) bool {
+	if claimedUserID == "" || claimedUserID == currentUserID(c) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"success": false,
+		"error":   "Нельзя выполнять действие от имени другого пользователя",
+	})
+	return true
 }
 
 // Login - обработчик POST /api/login
@@ -50,17 +90,47 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// Защита от перебора: задержка/блокировка по логину и IP.
+	// Попытка засчитывается сразу, удачный вход её откатывает.
+	ip := c.ClientIP()
+	retryAfter, err := loginGuard.Attempt(req.Username, ip)
+	if errors.Is(err, auth.ErrTooManyAttempts) {
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		recordAuthEvent(c, EventLoginBlocked, "", req.Username, "", fmt.Sprintf("retry after %ds", seconds))
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.JSON(http.StatusTooManyRequests, LoginResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Слишком много попыток входа, повторите через %d сек", seconds),
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("⚠️  Ошибка счётчика попыток входа: %v", err)
+		c.JSON(http.StatusInternalServerError, LoginResponse{
+			Success: false,
+			Error:   "Ошибка при проверке учётных данных",
+		})
+		return
+	}
+
 	db := database.GetDB()
 	var user models.User
 
-	// Ищем пользователя по имени
+	// Ищем пользователя по имени. Ответ одинаковый для неизвестного логина
+	// и неверного пароля, чтобы нельзя было проверить существование учётки.
 	if err := db.First(&user, "username = ?", req.Username).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			// Выравниваем время ответа с проверкой реального хеша
+			auth.CheckPassword(dummyPasswordHash, req.Password)
+			recordAuthEvent(c, EventLoginFailure, "", req.Username, "", "unknown user")
 			c.JSON(http.StatusUnauthorized, LoginResponse{
 				Success: false,
-				Error:   "Пользователь не найден",
+				Error:   errInvalidCredentials,
 			})
 		} else {
+			if err := loginGuard.Release(req.Username, ip); err != nil {
+				log.Printf("⚠️  Ошибка счётчика попыток входа: %v", err)
+			}
 			c.JSON(http.StatusInternalServerError, LoginResponse{
 				Success: false,
 				Error:   "Ошибка при проверке учётных данных",
@@ -69,40 +139,65 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Проверяем пароль (сравниваем хеши)
-	hashedPassword := hashPassword(req.Password)
-	if user.PasswordHash != hashedPassword {
+	// Проверяем пароль (bcrypt; старые SHA-256 хеши тоже принимаются)
+	ok, needsRehash := auth.CheckPassword(user.PasswordHash, req.Password)
+	if !ok {
+		recordAuthEvent(c, EventLoginFailure, user.ID, req.Username, "", "wrong password")
 		c.JSON(http.StatusUnauthorized, LoginResponse{
 			Success: false,
-			Error:   "Неверный пароль",
+			Error:   errInvalidCredentials,
+		})
+		return
+	}
+	if err := loginGuard.Success(req.Username, ip); err != nil {
+		log.Printf("⚠️  Ошибка счётчика попыток входа: %v", err)
+	}
+
+	// Прозрачно обновляем устаревший хеш на bcrypt
+	if needsRehash {
+		if newHash, err := auth.HashPassword(req.Password); err == nil {
+			if err := db.Model(&user).Update("password_hash", newHash).Error; err != nil {
+				log.Printf("⚠️  Не удалось обновить хеш пароля пользователя %s: %v", user.ID, err)
+			}
+		}
+	}
+
+	// Успешная авторизация - создаём сессию и выпускаем подписанный JWT
+	token, expiresAt, session, err := startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, LoginResponse{
+			Success: false,
+			Error:   "Ошибка при создании токена",
 		})
 		return
 	}
+	recordAuthEvent(c, EventLoginSuccess, user.ID, user.Username, session.ID, "")
 
-	// Успешная авторизация
-	// ⚠️ В production нужна генерация JWT токена!
 	c.JSON(http.StatusOK, LoginResponse{
-		Success:  true,
-		Message:  "Успешная авторизация",
-		UserID:   user.ID,
-		Username: user.Username,
-		Role:     user.Role,
-		Token:    fmt.Sprintf("bearer_%s", user.ID), // Упрощённый временный токен
+		Success:            true,
+		Message:            "Успешная авторизация",
+		UserID:             user.ID,
+		Username:           user.Username,
+		Role:               user.Role,
+		Token:              token,
+		ExpiresAt:          &expiresAt,
+		MustChangePassword: user.MustChangePassword,
 	})
 }
 
 // CurrentUser - обработчик GET /api/me
 // Возвращает информацию о текущем пользователе (требует авторизацию)
 type CurrentUserResponse struct {
-	Success bool         `json:"success"`
-	User    *models.User `json:"user,omitempty"`
-	Error   string       `json:"error,omitempty"`
+	Success     bool              `json:"success"`
+	User        *models.User      `json:"user,omitempty"`
+	Permissions []auth.Permission `json:"permissions,omitempty"`
+	Error       string            `json:"error,omitempty"`
 }
 
 func CurrentUser(c *gin.Context) {
-	// Получаем userID из контекста (должен быть установлен middleware'ем авторизации)
-	userID, exists := c.Get("userID")
-	if !exists {
+	// Получаем userID из контекста (устанавливается middleware'ем авторизации)
+	userID := currentUserID(c)
+	if userID == "" {
 		c.JSON(http.StatusUnauthorized, CurrentUserResponse{
 			Success: false,
 			Error:   "Пользователь не авторизован",
@@ -129,7 +224,8 @@ func CurrentUser(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, CurrentUserResponse{
-		Success: true,
-		User:    &user,
+		Success:     true,
+		User:        &user,
+		Permissions: auth.PermissionsFor(user.Role),
 	})
 }
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"QR-GENERATOR/internal/database"
+	"QR-GENERATOR/internal/gs1"
+	"QR-GENERATOR/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errBarcodeTaken - штрихкод уже привязан к другому товару
+var errBarcodeTaken = errors.New("barcode belongs to another item")
+
+// findItemIDByBarcode ищет товар по заводскому штрихкоду: EAN/UPC в любом
+// представлении, GS1 с GTIN (партия и срок при поиске не важны) или код как есть
+func findItemIDByBarcode(db *gorm.DB, code string) (string, error) {
+	key, _ := gs1.Key(code)
+	var barcode models.ItemBarcode
+	if err := db.Where("code = ?", key).First(&barcode).Error; err != nil {
+		return "", err
+	}
+	return barcode.ItemID, nil
+}
+
+// attachBarcode привязывает штрихкод к товару. Повторная привязка к тому же
+// товару ничего не меняет, к другому - errBarcodeTaken.
+func attachBarcode(tx *gorm.DB, itemID, code string) (models.ItemBarcode, error) {
+	key, kind := gs1.Key(code)
+	var existing models.ItemBarcode
+	err := tx.Where("code = ?", key).First(&existing).Error
+	if err == nil {
+		if existing.ItemID != itemID {
+			return existing, errBarcodeTaken
+		}
+		return existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return existing, err
+	}
+
+	barcode := models.ItemBarcode{ItemID: itemID, Code: key, Kind: kind}
+	return barcode, tx.Create(&barcode).Error
+}
+
+// AddBarcodeRequest - штрихкод для привязки к товару
+type AddBarcodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// AdminAddItemBarcode POST /api/admin/item/:id/barcodes — привязать штрихкод
+// {"code": "4006381333931"} или GS1 "(01)04006381333931(10)L-77"
+func AdminAddItemBarcode(c *gin.Context) {
+	id := c.Param("id")
+	db := database.GetDB()
+
+	var req AddBarcodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Code) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Пустой штрихкод"})
+		return
+	}
+
+	var item models.Item
+	if err := db.Select("id").First(&item, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Товар не найден"})
+		return
+	}
+
+	barcode, err := attachBarcode(db, item.ID, req.Code)
+	if errors.Is(err, errBarcodeTaken) {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Штрихкод уже привязан к другому товару", "item_id": barcode.ItemID})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "barcode": barcode})
+}
+
+// AdminDeleteItemBarcode DELETE /api/admin/item/:id/barcodes/:barcode_id
+func AdminDeleteItemBarcode(c *gin.Context) {
+	db := database.GetDB()
+
+	result := db.Where("id = ? AND item_id = ?", c.Param("barcode_id"), c.Param("id")).Delete(&models.ItemBarcode{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Штрихкод не найден"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ParseBarcode POST /api/barcode/parse — разбор GS1 без поиска товара
+// {"code": "]C1010400638133393110L-77\u001d17260315"}
+func ParseBarcode(c *gin.Context) {
+	var req AddBarcodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	key, kind := gs1.Key(req.Code)
+	resp := gin.H{"success": true, "key": key, "kind": kind}
+	if data, err := gs1.Parse(req.Code); err == nil {
+		resp["gs1"] = data
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,750 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"QR-GENERATOR/internal/database"
+	"QR-GENERATOR/internal/inventory"
+	"QR-GENERATOR/internal/locations"
+	"QR-GENERATOR/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Статусы инвентаризации: counting → review → posted; из review можно
+// вернуться в counting пересчётом расхождений, до проведения - отменить
+const (
+	CountStatusCounting  = "counting"
+	CountStatusReview    = "review"
+	CountStatusPosted    = "posted"
+	CountStatusCancelled = "cancelled"
+)
+
+// Статусы строки инвентаризации
+const (
+	CountLinePending  = "pending"
+	CountLineCounted  = "counted"
+	CountLineAdjusted = "adjusted"
+)
+
+var (
+	// errCountChanged - статус инвентаризации изменил другой запрос
+	errCountChanged = errors.New("stock count was modified concurrently")
+	// errReasonRequired - у строки с расхождением нет причины корректировки
+	errReasonRequired = errors.New("adjustment reason required")
+	// errNothingToRecount - нет строк для пересчёта
+	errNothingToRecount = errors.New("nothing to recount")
+	// errCountOverlap - ячейки уже входят в открытую инвентаризацию
+	errCountOverlap = errors.New("bins are already being counted")
+)
+
+// countStartLockKey - ключ advisory-блокировки Postgres на запуск инвентаризаций
+const countStartLockKey = 7315001
+
+// StartCountRequest - новая инвентаризация: выбранные локации (склад, зона,
+// полка или ячейка) считаются вместе со всеми вложенными ячейками
+type StartCountRequest struct {
+	Name        string   `json:"name"`
+	LocationIDs []string `json:"location_ids" binding:"required,min=1"`
+	Blind       bool     `json:"blind"` // не показывать учётный остаток при счёте
+}
+
+// RecordCountRequest - посчитанное количество товара в ячейке
+type RecordCountRequest struct {
+	LocationID string `json:"location_id" binding:"required"`
+	ItemID     string `json:"item_id" binding:"required"` // ID или заводской штрихкод
+	Quantity   *int   `json:"quantity" binding:"required,min=0"`
+}
+
+// RecountRequest - какие строки пересчитать (пусто - все с расхождением)
+type RecountRequest struct {
+	LineIDs []int64 `json:"line_ids"`
+}
+
+// PostCountRequest - причины корректировок: общая и по строкам
+type PostCountRequest struct {
+	Reason string          `json:"reason"`
+	Lines  []PostCountLine `json:"lines"`
+}
+
+// PostCountLine - причина корректировки по строке
+type PostCountLine struct {
+	LineID int64  `json:"line_id"`
+	Reason string `json:"reason"`
+}
+
+// CountVariance - строка отчёта о расхождениях
+type CountVariance struct {
+	LineID          int64  `json:"line_id"`
+	ItemID          string `json:"item_id"`
+	Name            string `json:"name"`
+	SKU             string `json:"sku"`
+	LocationID      string `json:"location_id"`
+	LocationCode    string `json:"location_code"`
+	Expected        int    `json:"expected"`
+	Counted         *int   `json:"counted"` // nil - не посчитан, корректировки не будет
+	PreviousCounted *int   `json:"previous_counted,omitempty"`
+	Variance        int    `json:"variance"`   // посчитано - учётный остаток
+	BookTotal       int    `json:"book_total"` // Item.Quantity - общий остаток товара по учёту
+	Status          string `json:"status"`
+}
+
+// CountSummary - итоги инвентаризации
+type CountSummary struct {
+	Lines     int `json:"lines"`
+	Counted   int `json:"counted"`
+	Uncounted int `json:"uncounted"`
+	Matched   int `json:"matched"`
+	Variances int `json:"variances"`
+	Surplus   int `json:"surplus"`  // единиц излишка
+	Shortage  int `json:"shortage"` // единиц недостачи
+}
+
+// countLineView - строка в ответе; при слепом счёте учётный остаток скрыт
+type countLineView struct {
+	models.StockCountLine
+	ExpectedQuantity *int `json:"expected_quantity,omitempty"`
+}
+
+// StartCount POST /api/counts
+// {"name": "Зона B, октябрь", "location_ids": ["loc_zoneb"], "blind": true}
+// Создаёт строки по всем остаткам в ячейках выбранных локаций. Ячейка может
+// входить только в одну незавершённую инвентаризацию.
+func StartCount(c *gin.Context) {
+	var req StartCountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Невалидные данные: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var roots []models.Location
+	if err := db.Where("id IN ?", req.LocationIDs).Find(&roots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if len(roots) != len(uniqueStrings(req.LocationIDs)) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Локация не найдена"})
+		return
+	}
+	bins, err := binsUnder(db, roots)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if len(bins) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "В выбранных локациях нет ячеек"})
+		return
+	}
+
+	ids := make([]string, len(roots))
+	for i, r := range roots {
+		ids[i] = r.ID
+	}
+	count := models.StockCount{
+		ID:          fmt.Sprintf("CNT-%s-%s", time.Now().Format("20060102"), uuid.New().String()[:4]),
+		Name:        req.Name,
+		LocationIDs: strings.Join(ids, ","),
+		Blind:       req.Blind,
+		Round:       1,
+		Status:      CountStatusCounting,
+		CreatedBy:   currentUserID(c),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	binIDs := make([]string, 0, len(bins))
+	for id := range bins {
+		binIDs = append(binIDs, id)
+	}
+	var conflictID string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Одна ячейка - одна открытая инвентаризация, иначе корректировки
+		// задвоятся. Проверка и создание идут под общей блокировкой, чтобы
+		// два параллельных запуска по одним ячейкам не прошли оба.
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", countStartLockKey).Error; err != nil {
+			return err
+		}
+		var open []models.StockCount
+		if err := tx.Where("status IN ?", []string{CountStatusCounting, CountStatusReview}).Find(&open).Error; err != nil {
+			return err
+		}
+		for _, other := range open {
+			otherBins, err := countBins(tx, other)
+			if err != nil {
+				return err
+			}
+			for id := range bins {
+				if otherBins[id] {
+					conflictID = other.ID
+					return errCountOverlap
+				}
+			}
+		}
+
+		if err := tx.Create(&count).Error; err != nil {
+			return err
+		}
+		// Учётные остатки на начало; при вводе посчитанного они обновляются
+		var balances []models.StockBalance
+		err := tx.Select("stock_balances.*").
+			Joins("JOIN items ON items.id = stock_balances.item_id AND items.deleted_at IS NULL").
+			Where("stock_balances.location_id IN ? AND stock_balances.quantity > 0", binIDs).
+			Find(&balances).Error
+		if err != nil || len(balances) == 0 {
+			return err
+		}
+		lines := make([]models.StockCountLine, len(balances))
+		for i, b := range balances {
+			lines[i] = models.StockCountLine{
+				CountID:          count.ID,
+				ItemID:           b.ItemID,
+				LocationID:       b.LocationID,
+				ExpectedQuantity: b.Quantity,
+				Round:            1,
+				Status:           CountLinePending,
+			}
+		}
+		return tx.Create(&lines).Error
+	})
+	if errors.Is(err, errCountOverlap) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success":  false,
+			"error":    "Ячейки уже считаются в инвентаризации " + conflictID,
+			"count_id": conflictID,
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при создании инвентаризации: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Ошибка при создании инвентаризации"})
+		return
+	}
+
+	log.Printf("✓ Инвентаризация %s начата: ячеек %d (оператор: %s)", count.ID, len(bins), count.CreatedBy)
+	respondCount(c, db, count.ID)
+}
+
+// GetCounts GET /api/counts?status=counting — инвентаризации, новые сначала
+func GetCounts(c *gin.Context) {
+	db := database.GetDB()
+
+	query := db.Order("created_at DESC")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var counts []models.StockCount
+	if err := query.Find(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "counts": counts})
+}
+
+// GetCount GET /api/counts/:id — инвентаризация со строками. При слепом
+// счёте учётный остаток в строках скрыт, пока идёт счёт.
+func GetCount(c *gin.Context) {
+	respondCount(c, database.GetDB(), c.Param("id"))
+}
+
+// RecordCount POST /api/counts/:id/lines
+// {"location_id": "location1", "item_id": "item1", "quantity": 38}
+// Записывает посчитанное количество (повторный ввод заменяет его). Учётный
+// остаток строки фиксируется на момент ввода, поэтому перемещения во время
+// инвентаризации не дают ложных расхождений. Товар, которого нет в строках
+// (нашёлся не в своей ячейке), добавляется новой строкой.
+func RecordCount(c *gin.Context) {
+	id := c.Param("id")
+
+	var req RecordCountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Невалидные данные: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var count models.StockCount
+	if err := db.First(&count, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Инвентаризация не найдена"})
+		return
+	}
+	if count.Status != CountStatusCounting {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Счёт по инвентаризации завершён (" + count.Status + ")"})
+		return
+	}
+
+	bins, err := countBins(db, count)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if !bins[req.LocationID] {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Ячейка не входит в инвентаризацию"})
+		return
+	}
+
+	// Вместо ID можно передать заводской штрихкод товара
+	var item models.Item
+	err = db.First(&item, "id = ?", req.ItemID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if itemID, berr := findItemIDByBarcode(db, req.ItemID); berr == nil {
+			err = db.First(&item, "id = ?", itemID).Error
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Товар не найден"})
+		return
+	}
+
+	expected, err := inventory.Balance(db, item.ID, req.LocationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	var line models.StockCountLine
+	err = db.Where("count_id = ? AND item_id = ? AND location_id = ?", count.ID, item.ID, req.LocationID).First(&line).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	line.CountID = count.ID
+	line.ItemID = item.ID
+	line.LocationID = req.LocationID
+	line.ExpectedQuantity = expected
+	line.CountedQuantity = req.Quantity
+	line.Round = count.Round
+	line.Status = CountLineCounted
+	line.CountedBy = currentUserID(c)
+	line.CountedAt = &now
+	if err := db.Save(&line).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	line.Item = &item
+	c.JSON(http.StatusOK, gin.H{"success": true, "line": viewCountLine(count, line)})
+}
+
+// FinishCount POST /api/counts/:id/finish — завершить счёт и перейти к
+// проверке расхождений. Непосчитанные строки остаются без корректировки.
+func FinishCount(c *gin.Context) {
+	id := c.Param("id")
+	db := database.GetDB()
+
+	err := setCountStatus(db, id, CountStatusReview, CountStatusCounting)
+	if respondCountStatusError(c, err) {
+		return
+	}
+	respondVariances(c, db, id, false)
+}
+
+// GetCountVariances GET /api/counts/:id/variances[?all=true]
+// Расхождения посчитанного с учётом по ячейкам и общий учётный остаток
+// товара (Item.Quantity). all=true - вместе с совпавшими строками.
+func GetCountVariances(c *gin.Context) {
+	respondVariances(c, database.GetDB(), c.Param("id"), c.Query("all") == "true")
+}
+
+// RecountCount POST /api/counts/:id/recount {"line_ids": [12, 15]}
+// Возвращает инвентаризацию в счёт следующим раундом: строки с расхождением
+// (или указанные) и непосчитанные нужно посчитать заново, прежний результат
+// сохраняется в previous_counted.
+func RecountCount(c *gin.Context) {
+	id := c.Param("id")
+
+	var req RecountRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Невалидные данные: " + err.Error()})
+		return
+	}
+	selected := make(map[int64]bool, len(req.LineIDs))
+	for _, lineID := range req.LineIDs {
+		selected[lineID] = true
+	}
+
+	db := database.GetDB()
+	recounted := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var count models.StockCount
+		if err := tx.First(&count, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := setCountStatus(tx, id, CountStatusCounting, CountStatusReview); err != nil {
+			return err
+		}
+
+		var lines []models.StockCountLine
+		if err := tx.Where("count_id = ?", id).Find(&lines).Error; err != nil {
+			return err
+		}
+		round := count.Round + 1
+		for _, l := range lines {
+			if len(selected) > 0 && !selected[l.ID] {
+				continue
+			}
+			if l.CountedQuantity != nil && *l.CountedQuantity == l.ExpectedQuantity && !selected[l.ID] {
+				continue
+			}
+			err := tx.Model(&models.StockCountLine{}).Where("id = ?", l.ID).Updates(map[string]interface{}{
+				"previous_counted": l.CountedQuantity,
+				"counted_quantity": nil,
+				"round":            round,
+				"status":           CountLinePending,
+			}).Error
+			if err != nil {
+				return err
+			}
+			recounted++
+		}
+		if recounted == 0 {
+			return errNothingToRecount
+		}
+		return tx.Model(&models.StockCount{}).Where("id = ?", id).Update("round", round).Error
+	})
+	if errors.Is(err, errNothingToRecount) {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Расхождений для пересчёта нет"})
+		return
+	}
+	if respondCountStatusError(c, err) {
+		return
+	}
+
+	log.Printf("✓ Инвентаризация %s: пересчёт %d строк", id, recounted)
+	respondCount(c, db, id)
+}
+
+// PostCount POST /api/counts/:id/post
+// {"reason": "Инвентаризация", "lines": [{"line_id": 12, "reason": "Пересорт"}]}
+// Проводит расхождения корректировками остатков (StockAdjustment) одной
+// транзакцией. Каждой корректировке нужна причина - своя или общая.
+func PostCount(c *gin.Context) {
+	id := c.Param("id")
+
+	var req PostCountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Невалидные данные: " + err.Error()})
+		return
+	}
+	reasons := make(map[int64]string, len(req.Lines))
+	for _, l := range req.Lines {
+		reasons[l.LineID] = strings.TrimSpace(l.Reason)
+	}
+
+	db := database.GetDB()
+	userID := currentUserID(c)
+	var adjustments []models.StockAdjustment
+	var missingReason []int64
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := setCountStatus(tx, id, CountStatusPosted, CountStatusReview); err != nil {
+			return err
+		}
+
+		var lines []models.StockCountLine
+		if err := tx.Where("count_id = ? AND counted_quantity IS NOT NULL AND counted_quantity <> expected_quantity", id).
+			Order("id").Find(&lines).Error; err != nil {
+			return err
+		}
+		for _, l := range lines {
+			if reasons[l.ID] == "" && strings.TrimSpace(req.Reason) == "" {
+				missingReason = append(missingReason, l.ID)
+			}
+		}
+		if len(missingReason) > 0 {
+			return errReasonRequired
+		}
+
+		locked := make(map[string]bool)
+		for _, l := range lines {
+			if !locked[l.ItemID] {
+				var item models.Item
+				err := tx.First(&item, "id = ?", l.ItemID).Error
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					continue // товар удалён после счёта
+				}
+				if err != nil {
+					return err
+				}
+				if err := inventory.LockItem(tx, &item); err != nil {
+					return err
+				}
+				locked[l.ItemID] = true
+			}
+
+			before, err := inventory.Balance(tx, l.ItemID, l.LocationID)
+			if err != nil {
+				return err
+			}
+			delta := *l.CountedQuantity - l.ExpectedQuantity
+			if delta > 0 {
+				err = inventory.AddStock(tx, l.ItemID, l.LocationID, delta)
+			} else {
+				err = inventory.RemoveStock(tx, l.ItemID, l.LocationID, -delta)
+			}
+			if err != nil {
+				return err
+			}
+
+			reason := reasons[l.ID]
+			if reason == "" {
+				reason = strings.TrimSpace(req.Reason)
+			}
+			adj := models.StockAdjustment{
+				ItemID:      l.ItemID,
+				LocationID:  l.LocationID,
+				Quantity:    delta,
+				Before:      before,
+				After:       before + delta,
+				Reason:      reason,
+				CountID:     id,
+				CountLineID: l.ID,
+				UserID:      userID,
+				CreatedAt:   time.Now(),
+			}
+			if err := tx.Create(&adj).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.StockCountLine{}).Where("id = ?", l.ID).Update("status", CountLineAdjusted).Error; err != nil {
+				return err
+			}
+			adjustments = append(adjustments, adj)
+		}
+
+		now := time.Now()
+		return tx.Model(&models.StockCount{}).Where("id = ?", id).
+			Updates(map[string]interface{}{"posted_by": userID, "posted_at": &now}).Error
+	})
+
+	if errors.Is(err, errReasonRequired) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":  false,
+			"error":    "Укажите причину корректировки (общую reason или по строкам)",
+			"line_ids": missingReason,
+		})
+		return
+	}
+	if errors.Is(err, inventory.ErrInsufficientStock) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   "Остаток в ячейке изменился после счёта и меньше недостачи, пересчитайте расхождения",
+		})
+		return
+	}
+	if errors.Is(err, inventory.ErrConcurrentModification) {
+		respondConcurrentModification(c)
+		return
+	}
+	if respondCountStatusError(c, err) {
+		return
+	}
+
+	log.Printf("✓ Инвентаризация %s проведена: корректировок %d (оператор: %s)", id, len(adjustments), userID)
+	c.JSON(http.StatusOK, gin.H{"success": true, "adjustments": adjustments})
+}
+
+// CancelCount POST /api/counts/:id/cancel — отменить без корректировок
+func CancelCount(c *gin.Context) {
+	id := c.Param("id")
+	db := database.GetDB()
+
+	err := setCountStatus(db, id, CountStatusCancelled, CountStatusCounting, CountStatusReview)
+	if respondCountStatusError(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// setCountStatus переводит инвентаризацию в to из одного из статусов from.
+// Условие на статус блокирует строку: параллельный переход получит errCountChanged.
+func setCountStatus(tx *gorm.DB, id, to string, from ...string) error {
+	var count int64
+	if err := tx.Model(&models.StockCount{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	result := tx.Model(&models.StockCount{}).
+		Where("id = ? AND status IN ?", id, from).
+		Updates(map[string]interface{}{"status": to, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errCountChanged
+	}
+	return nil
+}
+
+// respondCountStatusError отвечает на ошибку перехода статуса; false - ошибки нет
+func respondCountStatusError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Инвентаризация не найдена"})
+	case errors.Is(err, errCountChanged):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Действие недоступно в текущем статусе инвентаризации"})
+	default:
+		log.Printf("Ошибка инвентаризации: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+	}
+	return true
+}
+
+// respondCount отвечает инвентаризацией со строками
+func respondCount(c *gin.Context, db *gorm.DB, id string) {
+	var count models.StockCount
+	err := db.Preload("Lines", func(tx *gorm.DB) *gorm.DB { return tx.Order("location_id, id") }).
+		Preload("Lines.Item", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped().Select("id", "name", "sku", "unit") }).
+		Preload("Lines.Location", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped().Select("id", "code") }).
+		First(&count, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Инвентаризация не найдена"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	lines := make([]countLineView, len(count.Lines))
+	for i, l := range count.Lines {
+		lines[i] = viewCountLine(count, l)
+	}
+	count.Lines = nil
+	c.JSON(http.StatusOK, gin.H{"success": true, "count": count, "lines": lines})
+}
+
+// respondVariances отвечает отчётом о расхождениях (после завершения счёта)
+func respondVariances(c *gin.Context, db *gorm.DB, id string, all bool) {
+	var count models.StockCount
+	if err := db.First(&count, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Инвентаризация не найдена"})
+		return
+	}
+	if count.Status == CountStatusCounting {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Счёт ещё идёт: завершите его, чтобы увидеть расхождения"})
+		return
+	}
+
+	var lines []models.StockCountLine
+	err := db.Preload("Item", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped() }).
+		Preload("Location", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped().Select("id", "code") }).
+		Where("count_id = ?", id).
+		Order("location_id, id").
+		Find(&lines).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	summary := CountSummary{Lines: len(lines)}
+	variances := []CountVariance{}
+	for _, l := range lines {
+		v := CountVariance{
+			LineID:          l.ID,
+			ItemID:          l.ItemID,
+			LocationID:      l.LocationID,
+			Expected:        l.ExpectedQuantity,
+			Counted:         l.CountedQuantity,
+			PreviousCounted: l.PreviousCounted,
+			Status:          l.Status,
+		}
+		if l.Item != nil {
+			v.Name, v.SKU, v.BookTotal = l.Item.Name, l.Item.SKU, l.Item.Quantity
+		}
+		if l.Location != nil {
+			v.LocationCode = l.Location.Code
+		}
+
+		switch {
+		case l.CountedQuantity == nil:
+			summary.Uncounted++
+		case *l.CountedQuantity == l.ExpectedQuantity:
+			summary.Counted++
+			summary.Matched++
+		default:
+			summary.Counted++
+			summary.Variances++
+			v.Variance = *l.CountedQuantity - l.ExpectedQuantity
+			if v.Variance > 0 {
+				summary.Surplus += v.Variance
+			} else {
+				summary.Shortage -= v.Variance
+			}
+		}
+		if all || l.CountedQuantity == nil || v.Variance != 0 {
+			variances = append(variances, v)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"count":     count,
+		"summary":   summary,
+		"variances": variances,
+	})
+}
+
+// viewCountLine скрывает учётный остаток строки, пока идёт слепой счёт
+func viewCountLine(count models.StockCount, line models.StockCountLine) countLineView {
+	v := countLineView{StockCountLine: line}
+	if !count.Blind || count.Status != CountStatusCounting {
+		expected := line.ExpectedQuantity
+		v.ExpectedQuantity = &expected
+	}
+	return v
+}
+
+// countBins - ячейки инвентаризации: выбранные локации и вложенные в них
+func countBins(db *gorm.DB, count models.StockCount) (map[string]bool, error) {
+	var roots []models.Location
+	if err := db.Where("id IN ?", strings.Split(count.LocationIDs, ",")).Find(&roots).Error; err != nil {
+		return nil, err
+	}
+	return binsUnder(db, roots)
+}
+
+// binsUnder - ID ячеек в локациях roots (вместе с ними самими)
+func binsUnder(db *gorm.DB, roots []models.Location) (map[string]bool, error) {
+	bins := make(map[string]bool)
+	for _, root := range roots {
+		var ids []string
+		err := locations.Under(db.Model(&models.Location{}), root).
+			Where("kind = ?", locations.KindBin).
+			Pluck("id", &ids).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			bins[id] = true
+		}
+	}
+	return bins, nil
+}
+
+// uniqueStrings - список без повторов
+func uniqueStrings(list []string) []string {
+	seen := make(map[string]bool, len(list))
+	var out []string
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -1,15 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"time"
 
 	"QR-GENERATOR/internal/database"
 	"QR-GENERATOR/internal/models"
+	"QR-GENERATOR/internal/qr"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type CreateEquipmentRequest struct {
@@ -151,5 +154,102 @@ func AdminDeleteEquipment(c *gin.Context) {
 	id := c.Param("id")
 	db := database.GetDB()
 	db.Delete(&models.Equipment{}, "id = ?", id)
+	qr.Invalidate(qr.TypeEquipment, id)
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
+
+// AdminGetEquipmentQR GET /api/admin/equipment/:id/qr — наклейка EQ на технику
+func AdminGetEquipmentQR(c *gin.Context) {
+	id := c.Param("id")
+	serveEntityQR(c, qr.TypeEquipment, id, "qr_eq_"+id)
+}
+
+// EquipmentPart - деталь, выданная на технику по всем заявкам
+type EquipmentPart struct {
+	ItemID     string     `json:"item_id,omitempty"`
+	Name       string     `json:"name"`
+	PartNumber string     `json:"part_number,omitempty"`
+	Unit       string     `json:"unit,omitempty"`
+	Issued     int        `json:"issued"`      // выдано всего
+	Orders     int        `json:"orders"`      // в скольких заявках
+	LastIssued *time.Time `json:"last_issued"` // последняя заявка с выдачей
+}
+
+// GetEquipmentCard GET /api/equipment/:id — карточка техники и история
+// ремонтов: все заявки на неё (новые сначала) и сводка выданных деталей.
+// :id - ID карточки или гос/инв номер; по QR этикетке EQ сканер открывает её.
+// Заявки без привязки к карточке (созданные до неё) находятся по номеру.
+func GetEquipmentCard(c *gin.Context) {
+	id := c.Param("id")
+	db := database.GetDB()
+
+	var eq models.Equipment
+	err := db.First(&eq, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = db.First(&eq, "LOWER(license_plate) = LOWER(?)", strings.TrimSpace(id)).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Техника не найдена"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	var orders []models.WorkOrder
+	err = db.Preload("Items").
+		Preload("Mechanic", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped().Select("id", "username", "role") }).
+		Where("equipment_id = ? OR (COALESCE(equipment_id, '') = '' AND LOWER(equipment_number) = LOWER(?))", eq.ID, eq.LicensePlate).
+		Order("created_at DESC").
+		Find(&orders).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"equipment": eq,
+		"orders":    orders,
+		"parts":     equipmentParts(orders),
+	})
+}
+
+// equipmentParts суммирует выданные детали по заявкам (orders - новые сначала,
+// сводка в том же порядке по последней выдаче). Детали из каталога
+// группируются по товару, ручной ввод - по номеру и названию.
+func equipmentParts(orders []models.WorkOrder) []EquipmentPart {
+	byKey := make(map[string]*EquipmentPart)
+	var parts []*EquipmentPart
+	for i := range orders {
+		order := &orders[i]
+		seen := make(map[string]bool)
+		for _, it := range order.Items {
+			if it.IssuedQuantity <= 0 {
+				continue
+			}
+			key := "item:" + it.ItemID
+			if it.ItemID == "" {
+				key = "manual:" + strings.ToLower(strings.TrimSpace(it.PartNumber)) + "|" + strings.ToLower(strings.TrimSpace(it.Name))
+			}
+			p, ok := byKey[key]
+			if !ok {
+				p = &EquipmentPart{ItemID: it.ItemID, Name: it.Name, PartNumber: it.PartNumber, Unit: it.Unit, LastIssued: &order.CreatedAt}
+				byKey[key] = p
+				parts = append(parts, p)
+			}
+			p.Issued += it.IssuedQuantity
+			if !seen[key] {
+				seen[key] = true
+				p.Orders++
+			}
+		}
+	}
+
+	out := make([]EquipmentPart, len(parts))
+	for i, p := range parts {
+		out[i] = *p
+	}
+	return out
+}
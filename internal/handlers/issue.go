@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"QR-GENERATOR/internal/database"
+	"QR-GENERATOR/internal/inventory"
+	"QR-GENERATOR/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Статусы выдачи заявки механика и её строк
+const (
+	OrderStatusIssued          = "issued"
+	OrderStatusPartiallyIssued = "partially_issued"
+)
+
+var (
+	// errAlreadyIssued - заявка уже выдана полностью
+	errAlreadyIssued = errors.New("work order already issued")
+	// errOrderChanged - заявку одновременно выдаёт другой запрос
+	errOrderChanged = errors.New("work order was modified concurrently")
+	// errShortage - остатков не хватает, выдача не выполнена
+	errShortage = errors.New("insufficient stock for work order")
+)
+
+// IssueOrderRequest - параметры выдачи (тело запроса необязательно)
+type IssueOrderRequest struct {
+	// AllowPartial - выдать то, что есть, вместо отказа при нехватке
+	AllowPartial bool `json:"allow_partial"`
+	// CreateSupplyRequests - создать заявки на снабжение на недостающее количество
+	CreateSupplyRequests bool `json:"create_supply_requests"`
+}
+
+// IssuedLine - сколько выдано по строке заявки и с каких локаций
+type IssuedLine struct {
+	LineID      int64                  `json:"line_id"`
+	ItemID      string                 `json:"item_id"`
+	Name        string                 `json:"name"`
+	Quantity    int                    `json:"quantity"`
+	Allocations []inventory.Allocation `json:"allocations"`
+}
+
+// ShortageLine - строка отчёта о нехватке
+type ShortageLine struct {
+	LineID          int64  `json:"line_id"`
+	ItemID          string `json:"item_id,omitempty"` // пусто - позиции нет в каталоге
+	Name            string `json:"name"`
+	Requested       int    `json:"requested"` // всего по строке
+	Issued          int    `json:"issued"`    // выдано с учётом этой выдачи
+	Available       int    `json:"available"` // было на складе перед выдачей
+	Missing         int    `json:"missing"`
+	ItemDeleted     bool   `json:"item_deleted,omitempty"` // товар удалён из каталога, заявка на снабжение не создаётся
+	SupplyRequestID string `json:"supply_request_id,omitempty"`
+}
+
+// IssueOrderResponse - результат выдачи
+type IssueOrderResponse struct {
+	Success   bool           `json:"success"`
+	Status    string         `json:"status,omitempty"`
+	Issued    []IssuedLine   `json:"issued,omitempty"`
+	Shortages []ShortageLine `json:"shortages,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// issuePlan - сколько выдать по строке; считается до списания,
+// чтобы при отказе ничего не трогать
+type issuePlan struct {
+	line      *models.WorkOrderItem
+	item      models.Item
+	available int
+	take      int
+	nonStock  bool // деталь не из каталога: выдаётся без списания остатков
+	deleted   bool // товар строки удалён из каталога: выдать нечего
+}
+
+// IssueOrder POST /api/mechanic/order/:id/issue — выдаёт детали и списывает остатки.
+// Наличие проверяется по каждой строке. Без allow_partial при нехватке выдача
+// не выполняется (409 и отчёт о нехватке); с allow_partial выдаётся доступное,
+// заявка переходит в partially_issued и её можно довыдать повторным запросом.
+func IssueOrder(c *gin.Context) {
+	id := c.Param("id")
+
+	var req IssueOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, IssueOrderResponse{Success: false, Error: "Невалидные данные: " + err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	var (
+		status    string
+		issued    []IssuedLine
+		shortages []ShortageLine
+	)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var order models.WorkOrder
+		if err := tx.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+			First(&order, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if order.Status == OrderStatusIssued {
+			return errAlreadyIssued
+		}
+
+		// Блокируем заявку: параллельная выдача той же заявки получит 409
+		result := tx.Model(&models.WorkOrder{}).
+			Where("id = ? AND status = ?", order.ID, order.Status).
+			Update("updated_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errOrderChanged
+		}
+
+		plans, err := planIssue(tx, order.Items)
+		if err != nil {
+			return err
+		}
+
+		total := 0
+		var shortTakes []int // p.take для каждой строки отчёта
+		for _, p := range plans {
+			total += p.take
+			if missing := p.line.Quantity - p.line.IssuedQuantity - p.take; missing > 0 {
+				shortages = append(shortages, ShortageLine{
+					LineID:      p.line.ID,
+					ItemID:      p.line.ItemID,
+					Name:        p.line.Name,
+					Requested:   p.line.Quantity,
+					Issued:      p.line.IssuedQuantity + p.take,
+					Available:   p.available,
+					Missing:     missing,
+					ItemDeleted: p.deleted,
+				})
+				shortTakes = append(shortTakes, p.take)
+			}
+		}
+		if len(shortages) > 0 && (!req.AllowPartial || total == 0) {
+			// Отчёт без выдачи: в нём выдано только то, что было выдано раньше
+			for i := range shortages {
+				shortages[i].Issued -= shortTakes[i]
+				shortages[i].Missing += shortTakes[i]
+			}
+			return errShortage
+		}
+
+		for _, p := range plans {
+			if p.take == 0 {
+				continue
+			}
+			var allocations []inventory.Allocation
+			if !p.nonStock {
+				var shortfall int
+				var err error
+				allocations, shortfall, err = inventory.Consume(tx, p.item, p.take)
+				if err != nil {
+					return err
+				}
+				if shortfall > 0 {
+					// Остатки проверены под блокировкой товара, сюда попадать не должны
+					return inventory.ErrInsufficientStock
+				}
+			}
+
+			p.line.IssuedQuantity += p.take
+			p.line.Status = OrderStatusPartiallyIssued
+			if p.line.IssuedQuantity >= p.line.Quantity {
+				p.line.Status = OrderStatusIssued
+			}
+			if err := tx.Model(p.line).Updates(map[string]interface{}{
+				"issued_quantity": p.line.IssuedQuantity,
+				"status":          p.line.Status,
+			}).Error; err != nil {
+				return err
+			}
+
+			issued = append(issued, IssuedLine{
+				LineID:      p.line.ID,
+				ItemID:      p.line.ItemID,
+				Name:        p.line.Name,
+				Quantity:    p.take,
+				Allocations: allocations,
+			})
+		}
+
+		status = OrderStatusIssued
+		if len(shortages) > 0 {
+			status = OrderStatusPartiallyIssued
+			if req.CreateSupplyRequests {
+				if err := requestShortages(tx, order, currentUserID(c), shortages); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Model(&models.WorkOrder{}).Where("id = ?", order.ID).Update("status", status).Error
+	})
+
+	// При отказе заявки на снабжение создаются отдельно: транзакция выдачи откатана
+	if errors.Is(err, errShortage) && req.CreateSupplyRequests {
+		var order models.WorkOrder
+		if ferr := db.First(&order, "id = ?", id).Error; ferr == nil {
+			if serr := requestShortages(db, order, currentUserID(c), shortages); serr != nil {
+				log.Printf("⚠️  Заявка %s: не удалось создать заявки на снабжение: %v", id, serr)
+			}
+		}
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, IssueOrderResponse{Success: false, Error: "Заявка не найдена"})
+	case errors.Is(err, errAlreadyIssued):
+		c.JSON(http.StatusConflict, IssueOrderResponse{Success: false, Error: "Заявка уже выдана"})
+	case errors.Is(err, errShortage):
+		c.JSON(http.StatusConflict, IssueOrderResponse{
+			Success:   false,
+			Shortages: shortages,
+			Error:     "Недостаточно товара на складе для выдачи",
+		})
+	case errors.Is(err, errOrderChanged), errors.Is(err, inventory.ErrConcurrentModification):
+		c.JSON(http.StatusConflict, IssueOrderResponse{
+			Success: false,
+			Error:   "Заявка или товар изменены другим пользователем, повторите выдачу",
+		})
+	case err != nil:
+		log.Printf("Ошибка при выдаче заявки %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, IssueOrderResponse{Success: false, Error: "Ошибка при выдаче заявки"})
+	default:
+		log.Printf("✓ Заявка %s: %s (строк выдано: %d, с нехваткой: %d)", id, status, len(issued), len(shortages))
+		c.JSON(http.StatusOK, IssueOrderResponse{
+			Success:   true,
+			Status:    status,
+			Issued:    issued,
+			Shortages: shortages,
+		})
+	}
+}
+
+// planIssue блокирует товары строк и считает, сколько можно выдать по каждой.
+// Возвращает план только для строк, по которым ещё что-то не выдано.
+func planIssue(tx *gorm.DB, lines []models.WorkOrderItem) ([]issuePlan, error) {
+	var plans []issuePlan
+	reserved := make(map[string]int) // одна деталь может быть в нескольких строках
+
+	for i := range lines {
+		line := &lines[i]
+		need := line.Quantity - line.IssuedQuantity
+		if need <= 0 {
+			continue
+		}
+		p := issuePlan{line: line}
+
+		// Деталь вне каталога (введена вручную) на складе не учитывается:
+		// выдаётся без проверки остатков, как получена
+		if line.ItemID == "" {
+			p.nonStock = true
+			p.available, p.take = need, need
+			plans = append(plans, p)
+			continue
+		}
+
+		// Товар удалён из каталога - остатков нет, строка уходит в нехватку
+		err := tx.First(&p.item, "id = ?", line.ItemID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			p.deleted = true
+			plans = append(plans, p)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if _, locked := reserved[p.item.ID]; !locked {
+			if err := inventory.LockItem(tx, &p.item); err != nil {
+				return nil, err
+			}
+		}
+		p.available = p.item.Quantity - reserved[p.item.ID]
+		if p.available < 0 {
+			p.available = 0
+		}
+
+		p.take = need
+		if p.take > p.available {
+			p.take = p.available
+		}
+		reserved[p.item.ID] += p.take
+		plans = append(plans, p)
+	}
+	return plans, nil
+}
+
+// requestShortages создаёт заявки на снабжение на недостающее количество.
+// Если по строке уже есть незакрытая заявка, новая не создаётся.
+func requestShortages(tx *gorm.DB, order models.WorkOrder, requestedBy string, shortages []ShortageLine) error {
+	for i := range shortages {
+		s := &shortages[i]
+		if s.ItemDeleted {
+			continue // такую поставку нельзя будет оприходовать
+		}
+
+		var existing models.SupplyRequest
+		err := tx.Where("work_order_item_id = ? AND status <> ?", s.LineID, "received").
+			First(&existing).Error
+		if err == nil {
+			s.SupplyRequestID = existing.ID
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		supplyReq := models.SupplyRequest{
+			ID:              uuid.New().String(),
+			ItemID:          s.ItemID,
+			ItemName:        s.Name,
+			WorkOrderID:     order.ID,
+			WorkOrderItemID: s.LineID,
+			RequestedBy:     requestedBy,
+			Quantity:        s.Missing,
+			Reason:          fmt.Sprintf("Нехватка при выдаче заявки %s (техника: %s)", order.ID, order.Equipment),
+			Status:          "created",
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+		}
+		if err := tx.Create(&supplyReq).Error; err != nil {
+			return err
+		}
+		s.SupplyRequestID = supplyReq.ID
+	}
+	return nil
+}
@@ -33,8 +33,20 @@ func GetItem(c *gin.Context) {
 	db := database.GetDB()
 	var item models.Item
 
-	// Получаем товар с информацией о локации (joinом)
-	if err := db.Preload("Location").First(&item, "id = ?", itemID).Error; err != nil {
+	// Вместо ID можно передать заводской штрихкод товара (EAN/UPC/GS1)
+	var count int64
+	if err := db.Model(&models.Item{}).Where("id = ?", itemID).Count(&count).Error; err == nil && count == 0 {
+		if id, err := findItemIDByBarcode(db, itemID); err == nil {
+			itemID = id
+		}
+	}
+
+	// Получаем товар с основной локацией, остатками по всем локациям и штрихкодами
+	if err := db.Preload("Location").
+		Preload("Balances", "quantity <> 0").
+		Preload("Balances.Location").
+		Preload("Barcodes").
+		First(&item, "id = ?", itemID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, ItemResponse{
 				Success: false,
@@ -56,13 +68,14 @@ func GetItem(c *gin.Context) {
 }
 
 // GetItemHistory - обработчик GET /api/item/:id/history
-// Возвращает историю всех перемещений товара
+// Возвращает историю всех перемещений товара и корректировок по инвентаризациям
 type ItemHistoryResponse struct {
-	Success   bool                  `json:"success"`
-	ItemID    string                `json:"item_id"`
-	Movements []models.ItemMovement `json:"movements,omitempty"`
-	Total     int64                 `json:"total"`
-	Error     string                `json:"error,omitempty"`
+	Success     bool                     `json:"success"`
+	ItemID      string                   `json:"item_id"`
+	Movements   []models.ItemMovement    `json:"movements,omitempty"`
+	Adjustments []models.StockAdjustment `json:"adjustments,omitempty"`
+	Total       int64                    `json:"total"`
+	Error       string                   `json:"error,omitempty"`
 }
 
 func GetItemHistory(c *gin.Context) {
@@ -114,10 +127,24 @@ func GetItemHistory(c *gin.Context) {
 
 	count = int64(len(movements))
 
+	var adjustments []models.StockAdjustment
+	if err := db.Where("item_id = ?", itemID).
+		Preload("Location").
+		Preload("User").
+		Order("created_at DESC").
+		Find(&adjustments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ItemHistoryResponse{
+			Success: false,
+			Error:   "Ошибка при получении истории",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, ItemHistoryResponse{
-		Success:   true,
-		ItemID:    itemID,
-		Movements: movements,
-		Total:     count,
+		Success:     true,
+		ItemID:      itemID,
+		Movements:   movements,
+		Adjustments: adjustments,
+		Total:       count,
 	})
 }
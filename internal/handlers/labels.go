@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"QR-GENERATOR/internal/auth"
+	"QR-GENERATOR/internal/database"
+	"QR-GENERATOR/internal/labels"
+	"QR-GENERATOR/internal/qr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxLabelsPerSheet - ограничение на один PDF/ZPL
+const maxLabelsPerSheet = 2000
+
+// Форматы вывода этикеток
+const (
+	labelFormatPDF = "pdf"
+	labelFormatZPL = "zpl"
+)
+
+// LabelsRequest - выбор объектов и параметры печати.
+// В GET ids передаются строкой через запятую, в POST - массивом.
+type LabelsRequest struct {
+	Type    string   `form:"type" json:"type"` // item, location, order, equipment
+	IDs     []string `form:"-" json:"ids"`
+	All     bool     `form:"all" json:"all"`
+	Layout  string   `form:"layout" json:"layout"`
+	Copies  int      `form:"copies" json:"copies"`
+	Start   int      `form:"start" json:"start"`     // первая свободная позиция на листе (PDF)
+	Format  string   `form:"format" json:"format"`   // pdf или zpl
+	DPI     int      `form:"dpi" json:"dpi"`         // ZPL: 203, 300, 600
+	QRMode  string   `form:"qr_mode" json:"qr_mode"` // ZPL: native (^BQ) или graphic (^GFA)
+	Printer string   `form:"-" json:"printer"`       // имя принтера из LABEL_PRINTERS
+}
+
+// AdminGetLabelLayouts GET /api/admin/labels/layouts — раскладки и настроенные принтеры
+func AdminGetLabelLayouts(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"layouts":  labels.Layouts(),
+		"default":  labels.DefaultLayout,
+		"printers": labels.PrinterNames(),
+	})
+}
+
+// AdminGetLabels GET /api/admin/labels — лист этикеток PDF или ZPL
+// ?type=item|location|order|equipment &ids=id1,id2 (или all=true) &layout=a4-3x8 &copies=1 &start=1
+// &format=pdf|zpl &dpi=203 &qr_mode=native|graphic
+func AdminGetLabels(c *gin.Context) {
+	var req LabelsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	req.IDs = splitIDs(c.Query("ids"))
+	if rejectOrderLabels(c, req) {
+		return
+	}
+	serveLabels(c, req)
+}
+
+// rejectOrderLabels отвечает 403, если этикетки заявок механиков запрашивает
+// роль без orders:manage (как у GET /api/mechanic/order/:id/label): права
+// на справочники не дают доступа к чужим заявкам.
+func rejectOrderLabels(c *gin.Context, req LabelsRequest) bool {
+	if req.Type != labels.KindOrder || auth.HasPermission(currentRole(c), auth.PermOrdersManage) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Недостаточно прав"})
+	return true
+}
+
+// GetOrderLabel GET /api/mechanic/order/:id/label?format=pdf|zpl — этикетка заявки
+func GetOrderLabel(c *gin.Context) {
+	var req LabelsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	req.Type = labels.KindOrder
+	req.IDs = []string{c.Param("id")}
+	req.All = false
+	serveLabels(c, req)
+}
+
+// AdminPrintLabels POST /api/admin/labels/print — отправить ZPL на принтер
+// {"type":"item","ids":["item1"],"printer":"склад","copies":1}
+func AdminPrintLabels(c *gin.Context) {
+	var req LabelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	req.Format = labelFormatZPL
+	if rejectOrderLabels(c, req) {
+		return
+	}
+
+	addr, err := labels.PrinterAddr(req.Printer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Неизвестный принтер: " + req.Printer})
+		return
+	}
+
+	file, err := buildLabels(req)
+	if err != nil {
+		respondLabelsError(c, err)
+		return
+	}
+
+	if err := labels.SendRaw(addr, file.Data); err != nil {
+		log.Printf("⚠️  Ошибка печати на принтер %s (%s): %v", req.Printer, addr, err)
+		c.JSON(http.StatusBadGateway, gin.H{"success": false, "error": "Принтер недоступен: " + req.Printer})
+		return
+	}
+
+	log.Printf("🖨  Отправлено этикеток: %d на принтер %s (пользователь %s)", file.Count, req.Printer, currentUserID(c))
+	c.JSON(http.StatusOK, gin.H{"success": true, "printed": file.Count, "printer": req.Printer})
+}
+
+// serveLabels отдаёт файл этикеток
+func serveLabels(c *gin.Context, req LabelsRequest) {
+	file, err := buildLabels(req)
+	if err != nil {
+		respondLabelsError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename="+file.Filename)
+	c.Data(http.StatusOK, file.ContentType, file.Data)
+}
+
+// labelFile - готовый файл этикеток
+type labelFile struct {
+	Data        []byte
+	ContentType string
+	Filename    string
+	Count       int // число этикеток
+}
+
+// labelsError - ошибка запроса этикеток с HTTP статусом
+type labelsError struct {
+	status int
+	msg    string
+}
+
+func (e *labelsError) Error() string { return e.msg }
+
+func badLabels(msg string) error { return &labelsError{status: http.StatusBadRequest, msg: msg} }
+
+// respondLabelsError отвечает статусом из labelsError (иначе 500)
+func respondLabelsError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	var le *labelsError
+	if errors.As(err, &le) {
+		status = le.status
+	}
+	c.JSON(status, gin.H{"success": false, "error": err.Error()})
+}
+
+// buildLabels загружает объекты и формирует PDF/ZPL
+func buildLabels(req LabelsRequest) (labelFile, error) {
+	if req.Format == "" {
+		req.Format = labelFormatPDF
+	}
+	if req.Format != labelFormatPDF && req.Format != labelFormatZPL {
+		return labelFile{}, badLabels("format должен быть pdf или zpl")
+	}
+	if req.Layout == "" && req.Format == labelFormatZPL {
+		req.Layout = labels.DefaultZPLLayout
+	}
+	layout, err := labels.GetLayout(req.Layout)
+	if err != nil {
+		return labelFile{}, badLabels("Неизвестная раскладка: " + req.Layout)
+	}
+	if req.Copies == 0 {
+		req.Copies = 1
+	}
+	if req.Copies < 1 || req.Copies > 100 {
+		return labelFile{}, badLabels("copies должен быть от 1 до 100")
+	}
+	if len(req.IDs) == 0 && !req.All {
+		return labelFile{}, badLabels("Укажите ids или all=true")
+	}
+
+	sheet, err := labels.Load(database.GetDB(), req.Type, req.IDs, req.All)
+	if errors.Is(err, labels.ErrUnknownKind) {
+		return labelFile{}, badLabels(err.Error())
+	}
+	if err != nil {
+		return labelFile{}, err
+	}
+	if len(sheet) == 0 {
+		return labelFile{}, &labelsError{status: http.StatusNotFound, msg: "Ничего не найдено"}
+	}
+
+	// Копии одной этикетки идут подряд
+	if req.Copies > 1 {
+		repeated := make([]labels.Label, 0, len(sheet)*req.Copies)
+		for _, l := range sheet {
+			for i := 0; i < req.Copies; i++ {
+				repeated = append(repeated, l)
+			}
+		}
+		sheet = repeated
+	}
+	if len(sheet) > maxLabelsPerSheet {
+		return labelFile{}, badLabels(fmt.Sprintf("Слишком много этикеток (максимум %d)", maxLabelsPerSheet))
+	}
+
+	var buf bytes.Buffer
+	if req.Format == labelFormatZPL {
+		opts := labels.DefaultZPLOptions()
+		if req.DPI != 0 {
+			opts.DPI = req.DPI
+		}
+		if req.QRMode != "" {
+			opts.QRMode = req.QRMode
+		}
+		if err := opts.Validate(); err != nil {
+			return labelFile{}, badLabels(err.Error())
+		}
+		if err := labels.RenderZPL(&buf, layout, sheet, opts); err != nil {
+			return labelFile{}, badLabels(err.Error())
+		}
+		return labelFile{Data: buf.Bytes(), ContentType: "text/plain; charset=utf-8", Filename: "labels_" + layout.Name + ".zpl", Count: len(sheet)}, nil
+	}
+
+	if err := labels.Render(&buf, layout, sheet, req.Start); err != nil {
+		log.Printf("Ошибка формирования PDF этикеток: %v", err)
+		if errors.Is(err, labels.ErrFontNotFound) {
+			return labelFile{}, errors.New("Не найден шрифт для этикеток (задайте LABEL_FONT)")
+		}
+		return labelFile{}, errors.New("Ошибка формирования PDF")
+	}
+	return labelFile{Data: buf.Bytes(), ContentType: "application/pdf", Filename: "labels_" + layout.Name + ".pdf", Count: len(sheet)}, nil
+}
+
+// labelTypes - type этикетки для типа QR
+var labelTypes = map[qr.Type]string{
+	qr.TypeItem:      labels.KindItem,
+	qr.TypeLocation:  labels.KindLocation,
+	qr.TypeWorkOrder: labels.KindOrder,
+	qr.TypeEquipment: labels.KindEquipment,
+}
+
+// splitIDs разбирает "id1,id2" из query
+func splitIDs(s string) []string {
+	var ids []string
+	for _, id := range strings.Split(s, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
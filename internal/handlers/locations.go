@@ -0,0 +1,695 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"QR-GENERATOR/internal/database"
+	"QR-GENERATOR/internal/inventory"
+	"QR-GENERATOR/internal/locations"
+	"QR-GENERATOR/internal/models"
+	"QR-GENERATOR/internal/qr"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreateLocationRequest - новая локация. Без kind создаётся ячейка (bin),
+// без parent_id - локация верхнего уровня.
+type CreateLocationRequest struct {
+	Code        string `json:"code" binding:"required"`
+	Description string `json:"description"`
+	Kind        string `json:"kind"`      // warehouse, zone, row, section, shelf, bin
+	ParentID    string `json:"parent_id"` // локация уровнем выше
+	Row         string `json:"row"`       // для вложенной локации - код ряда-предка
+	Section     string `json:"section"`
+	Shelf       string `json:"shelf"`
+	LocationCapacity
+}
+
+// LocationCapacity - ограничения локации вместе с вложенными (0 / пусто - нет)
+type LocationCapacity struct {
+	MaxUnits          int     `json:"max_units" binding:"min=0"`
+	MaxWeight         float64 `json:"max_weight" binding:"min=0"` // кг
+	MaxVolume         float64 `json:"max_volume" binding:"min=0"` // л
+	AllowedCategories string  `json:"allowed_categories"`         // через запятую
+}
+
+func (lc LocationCapacity) apply(loc *models.Location) {
+	loc.MaxUnits = lc.MaxUnits
+	loc.MaxWeight = lc.MaxWeight
+	loc.MaxVolume = lc.MaxVolume
+	loc.AllowedCategories = locations.NormalizeCategories(lc.AllowedCategories)
+}
+
+// AdminGetLocations GET /api/admin/locations
+// ?under=<id> - локация и всё, что в ней; ?kind=bin - только уровень;
+// ?tree=true - вложенным деревом (children) вместо плоского списка;
+// ?status=active|disabled|all - выведенные из работы локации
+func AdminGetLocations(c *gin.Context) {
+	db := database.GetDB()
+	query := db.Order("code ASC")
+	switch c.DefaultQuery("status", "active") {
+	case "disabled":
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	case "all":
+		query = query.Unscoped()
+	}
+
+	if under := c.Query("under"); under != "" {
+		var root models.Location
+		if err := db.First(&root, "id = ?", under).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Локация не найдена"})
+			return
+		}
+		query = locations.Under(query, root)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var list []models.Location
+	if err := query.Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if c.Query("tree") == "true" {
+		list = locations.Tree(list)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "locations": list})
+}
+
+// AdminCreateLocation POST /api/admin/location
+// {"code": "B-03-2-4", "kind": "bin", "parent_id": "loc_1a2b3c4d"}
+func AdminCreateLocation(c *gin.Context) {
+	var req CreateLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if req.Kind == "" {
+		req.Kind = locations.KindBin
+	}
+
+	db := database.GetDB()
+	if rejectTakenCode(c, db, req.Code, "") {
+		return
+	}
+
+	var parent *models.Location
+	if req.ParentID != "" {
+		parent = &models.Location{}
+		if err := db.First(parent, "id = ?", req.ParentID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Родительская локация не найдена"})
+			return
+		}
+	}
+	if err := locations.CheckParent(req.Kind, parent); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	loc := models.Location{
+		ID:          "loc_" + uuid.New().String()[:8],
+		Code:        req.Code,
+		Description: req.Description,
+		Kind:        req.Kind,
+		Row:         req.Row,
+		Section:     req.Section,
+		Shelf:       req.Shelf,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	loc.Path = locations.PathOf(parent, loc.ID)
+	req.LocationCapacity.apply(&loc)
+	if parent != nil {
+		loc.ParentID = parent.ID
+		ancestors, err := locations.Ancestors(db, *parent)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		locations.ApplyPlace(&loc, append(ancestors, *parent))
+	}
+
+	if err := db.Create(&loc).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"location": loc,
+		"qr_url":   qrImageURL(qr.TypeLocation, loc.ID),
+	})
+}
+
+// UpdateLocationRequest - изменение локации. Родитель меняется через /move.
+type UpdateLocationRequest struct {
+	Code        string `json:"code" binding:"required"`
+	Description string `json:"description"`
+	Kind        string `json:"kind"` // пусто - уровень не меняется
+	Row         string `json:"row"`
+	Section     string `json:"section"`
+	Shelf       string `json:"shelf"`
+	LocationCapacity
+}
+
+// AdminUpdateLocation PUT /api/admin/location/:id
+// Ячейку с товаром нельзя сделать контейнером, а контейнер - уровнем не
+// ниже вложенных в него локаций. Ряд/секция/полка вложенных пересчитываются.
+func AdminUpdateLocation(c *gin.Context) {
+	id := c.Param("id")
+	db := database.GetDB()
+
+	var req UpdateLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	var loc models.Location
+	if err := db.First(&loc, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Локация не найдена"})
+		return
+	}
+	if req.Kind == "" {
+		req.Kind = loc.Kind
+	}
+	if req.Code != loc.Code && rejectTakenCode(c, db, req.Code, loc.ID) {
+		return
+	}
+
+	if req.Kind != loc.Kind {
+		err := locations.CheckKind(db, loc, req.Kind)
+		if errors.Is(err, locations.ErrUnknownKind) || errors.Is(err, locations.ErrBadParent) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		if loc.Kind == locations.KindBin {
+			refs, err := getLocationRefs(db, loc.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+				return
+			}
+			if refs.Units > 0 || refs.Items > 0 {
+				c.JSON(http.StatusConflict, gin.H{
+					"success": false,
+					"error":   "В ячейке хранится товар, уровень менять нельзя: " + refs.String(),
+					"units":   refs.Units,
+					"items":   refs.Items,
+				})
+				return
+			}
+		}
+	}
+
+	loc.Code = req.Code
+	loc.Description = req.Description
+	loc.Kind = req.Kind
+	loc.Row = req.Row
+	loc.Section = req.Section
+	loc.Shelf = req.Shelf
+	loc.UpdatedAt = time.Now()
+	req.LocationCapacity.apply(&loc)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&loc).Error; err != nil {
+			return err
+		}
+		// Код ряда/секции/полки мог измениться - он указан во вложенных локациях
+		return locations.SyncPlace(tx, loc)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	db.First(&loc, "id = ?", loc.ID)
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "location": loc})
+}
+
+// AdminDeleteLocation DELETE /api/admin/location/:id[?relocate_to=<id ячейки>]
+// Выводит локацию из работы (мягкое удаление, восстанавливается через
+// /restore). Локацию с вложенными локациями не удаляет. Если в ячейке лежит
+// товар или она основная у товаров - без relocate_to отвечает 409 с их
+// количеством, с relocate_to переносит остатки и товары в указанную ячейку
+// одной транзакцией с удалением (с проверкой её вместимости и историей).
+func AdminDeleteLocation(c *gin.Context) {
+	id := c.Param("id")
+	relocateTo := c.Query("relocate_to")
+	db := database.GetDB()
+
+	var loc models.Location
+	if err := db.First(&loc, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Локация не найдена"})
+		return
+	}
+
+	refs, err := getLocationRefs(db, loc.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if refs.Children > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"success":  false,
+			"error":    fmt.Sprintf("В локации %d вложенных локаций: перенесите или удалите их", refs.Children),
+			"children": refs.Children,
+		})
+		return
+	}
+	if relocateTo == "" && (refs.Units > 0 || refs.Items > 0) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   "Локация используется: " + refs.String() + ". Укажите relocate_to - ячейку для переноса",
+			"units":   refs.Units,
+			"items":   refs.Items,
+		})
+		return
+	}
+
+	var target models.Location
+	if relocateTo != "" {
+		if relocateTo == loc.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Нельзя перенести товар в удаляемую локацию"})
+			return
+		}
+		if err := db.First(&target, "id = ?", relocateTo).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Локация для переноса не найдена"})
+			return
+		}
+		if target.Kind != locations.KindBin {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Товар хранится только в ячейке (bin), а " + target.Code + " - " + target.Kind})
+			return
+		}
+	}
+
+	userID := currentUserID(c)
+	var movements []models.ItemMovement
+	var violations []locations.Violation
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if relocateTo != "" {
+			var err error
+			movements, violations, err = relocateStock(tx, loc, target, userID)
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&loc).Error
+	})
+	if errors.Is(err, locations.ErrOverCapacity) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success":    false,
+			"error":      "Ячейка для переноса переполнена или не подходит для товара: " + violations[0].Message,
+			"violations": violations,
+		})
+		return
+	}
+	if errors.Is(err, inventory.ErrConcurrentModification) {
+		respondConcurrentModification(c)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при удалении локации %s: %v", loc.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Ошибка при удалении локации"})
+		return
+	}
+
+	// Этикетка выведенной локации больше не нужна
+	qr.Invalidate(qr.TypeLocation, loc.ID)
+	if err := os.Remove(qr.FilePath(qr.TypeLocation, loc.ID)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Не удалось удалить QR-файл локации %s: %v", loc.ID, err)
+	}
+
+	log.Printf("✓ Локация %s (%s) выведена из работы, перенесено позиций: %d", loc.Code, loc.ID, len(movements))
+
+	resp := gin.H{"success": true, "movements": movements}
+	if len(violations) > 0 {
+		resp["warnings"] = violations
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// AdminRestoreLocation POST /api/admin/location/:id/restore — вернуть
+// выведенную из работы локацию. Родитель должен быть в работе.
+func AdminRestoreLocation(c *gin.Context) {
+	id := c.Param("id")
+	db := database.GetDB()
+
+	var loc models.Location
+	if err := db.Unscoped().First(&loc, "id = ? AND deleted_at IS NOT NULL", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Выведенная из работы локация не найдена"})
+		return
+	}
+	if loc.ParentID != "" {
+		if err := db.Select("id").First(&models.Location{}, "id = ?", loc.ParentID).Error; err != nil {
+			c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Сначала восстановите родительскую локацию"})
+			return
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Model(&models.Location{}).Where("id = ?", loc.ID).
+			Updates(map[string]interface{}{"deleted_at": nil, "updated_at": time.Now()}).Error
+		if err != nil {
+			return err
+		}
+		// Пока локация была выведена, её контейнер могли переименовать
+		return locations.SyncPlace(tx, loc)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	db.First(&loc, "id = ?", loc.ID)
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "location": loc})
+}
+
+// MoveLocationRequest - новый родитель; пусто - на верхний уровень
+type MoveLocationRequest struct {
+	ParentID string `json:"parent_id"`
+}
+
+// AdminMoveLocation POST /api/admin/location/:id/move — перенести локацию
+// вместе со всем содержимым в другой контейнер (полку в другую секцию,
+// ячейку на другую полку). Товары остаются в своих ячейках.
+func AdminMoveLocation(c *gin.Context) {
+	id := c.Param("id")
+	db := database.GetDB()
+
+	var req MoveLocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	var loc models.Location
+	if err := db.First(&loc, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Локация не найдена"})
+		return
+	}
+	var parent *models.Location
+	if req.ParentID != "" {
+		parent = &models.Location{}
+		if err := db.First(parent, "id = ?", req.ParentID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Родительская локация не найдена"})
+			return
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return locations.Move(tx, &loc, parent)
+	})
+	if errors.Is(err, locations.ErrBadParent) || errors.Is(err, locations.ErrCycle) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "location": loc})
+}
+
+// AdminUpdateLocationCapacity PUT /api/admin/location/:id/capacity
+// {"max_units": 200, "max_weight": 500, "max_volume": 0, "allowed_categories": "Фильтры,Масла"}
+// Новые ограничения действуют на следующие размещения; уже лежащий товар
+// не перемещается, текущая занятость возвращается в ответе.
+func AdminUpdateLocationCapacity(c *gin.Context) {
+	id := c.Param("id")
+	db := database.GetDB()
+
+	var req LocationCapacity
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	var loc models.Location
+	if err := db.First(&loc, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Локация не найдена"})
+		return
+	}
+	req.apply(&loc)
+	loc.UpdatedAt = time.Now()
+
+	err := db.Model(&loc).Select("max_units", "max_weight", "max_volume", "allowed_categories", "updated_at").Updates(&loc).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	usage, err := locations.UsageUnder(db, []string{loc.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "location": loc, "usage": usage[loc.ID]})
+}
+
+// LocationStock - товар в локации (у контейнера - в одной из вложенных ячеек)
+type LocationStock struct {
+	ItemID       string `json:"item_id"`
+	Name         string `json:"name"`
+	SKU          string `json:"sku"`
+	Unit         string `json:"unit"`
+	LocationID   string `json:"location_id"`
+	LocationCode string `json:"location_code"`
+	Quantity     int    `json:"quantity"`
+}
+
+// GetLocation GET /api/location/:id, GET /api/location?code=LOC-A1
+// Содержимое локации для экрана сканирования: товары с остатками (у полки или
+// зоны - по всем вложенным ячейкам) и последние перемещения в неё и из неё.
+// Вместо ID можно передать код. &limit=20 - число перемещений.
+func GetLocation(c *gin.Context) {
+	db := database.GetDB()
+
+	id := strings.TrimSpace(c.Param("id"))
+	code := strings.TrimSpace(c.Query("code"))
+	if id == "" && code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Укажите ID или code локации"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var loc models.Location
+	err = gorm.ErrRecordNotFound
+	if id != "" {
+		err = db.First(&loc, "id = ?", id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			code = id
+		}
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = db.First(&loc, "LOWER(code) = LOWER(?)", code).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Локация не найдена"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	ancestors, err := locations.Ancestors(db, loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if ancestors == nil {
+		ancestors = []models.Location{}
+	}
+	ids, err := locations.SubtreeIDs(db, loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	stock := []LocationStock{}
+	err = db.Table("stock_balances sb").
+		Select("sb.item_id, i.name, i.sku, i.unit, sb.location_id, l.code AS location_code, sb.quantity").
+		Joins("JOIN items i ON i.id = sb.item_id AND i.deleted_at IS NULL").
+		Joins("JOIN locations l ON l.id = sb.location_id").
+		Where("sb.location_id IN ? AND sb.quantity > 0", ids).
+		Order("l.code ASC, i.name ASC").
+		Scan(&stock).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	total := 0
+	for _, s := range stock {
+		total += s.Quantity
+	}
+
+	// Перемещения удалённых товаров и выведенных локаций тоже показываем
+	unscoped := func(tx *gorm.DB) *gorm.DB { return tx.Unscoped() }
+	var movements []models.ItemMovement
+	err = db.Preload("Item", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped().Select("id", "name", "sku", "unit") }).
+		Preload("FromLocation", unscoped).
+		Preload("ToLocation", unscoped).
+		Preload("User", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped().Select("id", "username", "role") }).
+		Where("from_location_id IN ? OR to_location_id IN ?", ids, ids).
+		Order("moved_at DESC").
+		Limit(limit).
+		Find(&movements).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"location":  loc,
+		"ancestors": ancestors,
+		"stock":     stock,
+		"total":     total,
+		"movements": movements,
+	})
+}
+
+// AdminGetLocationQR GET /api/admin/location/:id/qr
+// Параметры изображения — как у AdminGetItemQR
+func AdminGetLocationQR(c *gin.Context) {
+	id := c.Param("id")
+	serveEntityQR(c, qr.TypeLocation, id, "qr_loc_"+id)
+}
+
+// locationRefs - что держит локацию в работе
+type locationRefs struct {
+	Units    int   // остаток в локации
+	Items    int64 // товаров, у которых она основная
+	Children int64 // вложенных локаций
+}
+
+func (r locationRefs) String() string {
+	return fmt.Sprintf("остаток %d шт., основная локация у %d товаров", r.Units, r.Items)
+}
+
+func getLocationRefs(db *gorm.DB, id string) (locationRefs, error) {
+	var r locationRefs
+	err := db.Raw(`SELECT COALESCE(SUM(sb.quantity), 0) FROM stock_balances sb
+		JOIN items i ON i.id = sb.item_id AND i.deleted_at IS NULL
+		WHERE sb.location_id = ? AND sb.quantity > 0`, id).Scan(&r.Units).Error
+	if err != nil {
+		return r, err
+	}
+	if err := db.Model(&models.Item{}).Where("location_id = ?", id).Count(&r.Items).Error; err != nil {
+		return r, err
+	}
+	err = db.Model(&models.Location{}).Where("parent_id = ?", id).Count(&r.Children).Error
+	return r, err
+}
+
+// relocateStock переносит все остатки из loc в ячейку target и делает её
+// основной у товаров, которые ссылались на loc. Вызывается в транзакции.
+func relocateStock(tx *gorm.DB, loc, target models.Location, userID string) ([]models.ItemMovement, []locations.Violation, error) {
+	var balances []models.StockBalance
+	if err := tx.Where("location_id = ? AND quantity > 0", loc.ID).Find(&balances).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var movements []models.ItemMovement
+	var violations []locations.Violation
+	for _, b := range balances {
+		var item models.Item
+		err := tx.First(&item, "id = ?", b.ItemID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue // остаток удалённого товара
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := inventory.LockItem(tx, &item); err != nil {
+			return nil, nil, err
+		}
+
+		v, err := locations.CheckPlacement(tx, target, loc.ID, item, b.Quantity)
+		if err != nil {
+			return nil, nil, err
+		}
+		violations = append(violations, v...)
+		if len(v) > 0 && locations.RejectOverCapacity() {
+			return nil, violations, locations.ErrOverCapacity
+		}
+
+		if err := inventory.Move(tx, item.ID, loc.ID, target.ID, b.Quantity); err != nil {
+			return nil, nil, err
+		}
+		movement := models.ItemMovement{
+			ItemID:         item.ID,
+			FromLocationID: loc.ID,
+			ToLocationID:   target.ID,
+			Quantity:       b.Quantity,
+			UserID:         userID,
+			Notes:          "Локация " + loc.Code + " выведена из работы",
+			MovedAt:        time.Now(),
+		}
+		if err := tx.Create(&movement).Error; err != nil {
+			return nil, nil, err
+		}
+		movements = append(movements, movement)
+	}
+
+	err := tx.Model(&models.Item{}).Where("location_id = ?", loc.ID).Update("location_id", target.ID).Error
+	return movements, violations, err
+}
+
+// rejectTakenCode отвечает 409, если код занят другой локацией, в том
+// числе выведенной из работы (код уникален среди всех)
+func rejectTakenCode(c *gin.Context, db *gorm.DB, code, exceptID string) bool {
+	var other models.Location
+	err := db.Unscoped().Select("id", "deleted_at").Where("code = ? AND id <> ?", code, exceptID).First(&other).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return true
+	}
+	msg := "Код " + code + " уже занят"
+	if other.DeletedAt.Valid {
+		msg += " выведенной из работы локацией — восстановите её"
+	}
+	c.JSON(http.StatusConflict, gin.H{"success": false, "error": msg, "location_id": other.ID})
+	return true
+}
+
+// rejectNonBin отвечает 400, если товар нельзя хранить в локации: она не
+// найдена или это не ячейка. Пустой ID (товар не размещён) допустим.
+func rejectNonBin(c *gin.Context, db *gorm.DB, locationID string) bool {
+	loc, err := locations.RequireBin(db, locationID)
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Локация не найдена"})
+		return true
+	}
+	if errors.Is(err, locations.ErrNotBin) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Товар хранится только в ячейке (bin), а " + loc.Code + " - " + loc.Kind})
+		return true
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+	return true
+}
@@ -3,8 +3,10 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"QR-GENERATOR/internal/auth"
 	"QR-GENERATOR/internal/database"
 	"QR-GENERATOR/internal/models"
 
@@ -15,10 +17,11 @@ import (
 type CreateWorkOrderRequest struct {
 	Equipment       string               `json:"equipment" binding:"required"`
 	EquipmentNumber string               `json:"equipment_number" binding:"required"`
+	EquipmentID     string               `json:"equipment_id"` // выбранная карточка техники
 	WorkType        string               `json:"work_type" binding:"required"`
 	Priority        string               `json:"priority"`
 	Description     string               `json:"description"`
-	MechanicID      string               `json:"mechanic_id"`
+	MechanicID      string               `json:"mechanic_id"` // устарело: механик берётся из сессии
 	Items           []WorkOrderItemInput `json:"items" binding:"required,min=1"`
 }
 
@@ -39,6 +42,12 @@ func CreateWorkOrder(c *gin.Context) {
 		return
 	}
 
+	// Автор заявки — пользователь сессии
+	if rejectForeignActor(c, req.MechanicID) {
+		return
+	}
+	mechanicID := currentUserID(c)
+
 	db := database.GetDB()
 
 	priority := req.Priority
@@ -46,13 +55,27 @@ func CreateWorkOrder(c *gin.Context) {
 		priority = "normal"
 	}
 
+	// Привязка к карточке техники: по выбранному ID или по гос/инв номеру.
+	// По ней строится история ремонтов (GET /api/equipment/:id).
+	var eq models.Equipment
+	if req.EquipmentID != "" {
+		if err := db.First(&eq, "id = ?", req.EquipmentID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Техника не найдена: " + req.EquipmentID})
+			return
+		}
+		req.Equipment, req.EquipmentNumber = eq.Name, eq.LicensePlate
+	} else {
+		db.Where("LOWER(license_plate) = LOWER(?)", strings.TrimSpace(req.EquipmentNumber)).Limit(1).Find(&eq)
+	}
+
 	// Генерируем читаемый ID: WO-20240226-XXXX
 	orderID := fmt.Sprintf("WO-%s-%s", time.Now().Format("20060102"), uuid.New().String()[:4])
 	order := models.WorkOrder{
 		ID:              orderID,
-		MechanicID:      req.MechanicID,
+		MechanicID:      mechanicID,
 		Equipment:       req.Equipment,
 		EquipmentNumber: req.EquipmentNumber,
+		EquipmentID:     eq.ID,
 		WorkType:        req.WorkType,
 		Priority:        req.Priority,
 		Description:     req.Description,
@@ -93,15 +116,17 @@ func CreateWorkOrder(c *gin.Context) {
 			sID := fmt.Sprintf("REQ-%d%d", time.Now().Unix()%10000, i)
 
 			supplyReq := models.SupplyRequest{
-				ID:          sID,
-				ItemID:      it.ItemID,
-				ItemName:    it.Name, // Сохраняем имя товара
-				RequestedBy: req.MechanicID,
-				Quantity:    it.Quantity,
-				Reason:      fmt.Sprintf("Заявка %s: %s (Техника: %s)", order.ID, it.Justification, req.Equipment),
-				Status:      "created",
-				CreatedAt:   time.Now(),
-				UpdatedAt:   time.Now(),
+				ID:              sID,
+				ItemID:          it.ItemID,
+				WorkOrderID:     order.ID,
+				WorkOrderItemID: orderItem.ID,
+				ItemName:        it.Name, // Сохраняем имя товара
+				RequestedBy:     mechanicID,
+				Quantity:        it.Quantity,
+				Reason:          fmt.Sprintf("Заявка %s: %s (Техника: %s)", order.ID, it.Justification, req.Equipment),
+				Status:          "created",
+				CreatedAt:       time.Now(),
+				UpdatedAt:       time.Now(),
 			}
 			if err := db.Create(&supplyReq).Error; err != nil {
 				fmt.Println("Ошибка создания SupplyRequest:", err)
@@ -125,10 +150,10 @@ func CreateWorkOrder(c *gin.Context) {
 
 // GetMyOrders GET /api/mechanic/orders
 func GetMyOrders(c *gin.Context) {
+	// Механик видит только свои заявки; кладовщик/админ — все или по фильтру
 	mechanicID := c.Query("mechanic_id")
-	if mechanicID == "" {
-		// Берём из заголовка Authorization (упрощённо)
-		mechanicID = c.GetHeader("X-User-ID")
+	if currentRole(c) == auth.RoleMechanic {
+		mechanicID = currentUserID(c)
 	}
 
 	db := database.GetDB()
@@ -184,5 +209,11 @@ func GetWorkOrder(c *gin.Context) {
 		return
 	}
 
+	// Чужие заявки механику не показываем
+	if currentRole(c) == auth.RoleMechanic && order.MechanicID != currentUserID(c) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Заявка не найдена"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"success": true, "order": order})
 }
@@ -1,33 +1,44 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 
 	"QR-GENERATOR/internal/database"
+	"QR-GENERATOR/internal/inventory"
+	"QR-GENERATOR/internal/locations"
 	"QR-GENERATOR/internal/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // MoveRequest - запрос на перемещение товара
 type MoveRequest struct {
-	ItemID       string `json:"item_id" binding:"required"`
-	ToLocationID string `json:"to_location_id" binding:"required"`
-	UserID       string `json:"user_id" binding:"required"`
-	Notes        string `json:"notes"`
+	ItemID         string `json:"item_id" binding:"required"`
+	ToLocationID   string `json:"to_location_id" binding:"required"`
+	FromLocationID string `json:"from_location_id"`                   // по умолчанию - основная локация товара
+	Quantity       int    `json:"quantity" binding:"omitempty,min=1"` // 0 - весь остаток на исходной локации
+	Version        int    `json:"version"`                            // версия товара, которую видел клиент (необязательно)
+	UserID         string `json:"user_id"`                            // устарело: автор берётся из сессии, чужой ID отклоняется
+	Notes          string `json:"notes"`
 }
 
 // MoveResponse - ответ при успешном перемещении
 type MoveResponse struct {
-	Success  bool                 `json:"success"`
-	Message  string               `json:"message"`
-	Movement *models.ItemMovement `json:"movement,omitempty"`
+	Success  bool                  `json:"success"`
+	Message  string                `json:"message"`
+	Movement *models.ItemMovement  `json:"movement,omitempty"`
+	Balances []models.StockBalance `json:"balances,omitempty"`
+	Warnings []locations.Violation `json:"warnings,omitempty"` // превышены ограничения локации (CAPACITY_POLICY=warn)
 }
 
 // MoveItem - обработчик POST /api/move
-// Перемещает товар из текущей локации в новую и записывает в историю
+// Перемещает товар (целиком или часть количества) с одной локации на другую
+// и записывает в историю
 func MoveItem(c *gin.Context) {
 	var req MoveRequest
 
@@ -52,6 +63,12 @@ func MoveItem(c *gin.Context) {
 		return
 	}
 
+	// Клиент перемещает товар по устаревшим данным
+	if req.Version > 0 && req.Version != item.Version {
+		respondConcurrentModification(c)
+		return
+	}
+
 	// Проверяем, существует ли целевая локация
 	var targetLoc models.Location
 	if err := db.First(&targetLoc, "id = ?", req.ToLocationID).Error; err != nil {
@@ -61,55 +78,134 @@ func MoveItem(c *gin.Context) {
 		})
 		return
 	}
-
-	// Проверяем, существует ли пользователь
-	var user models.User
-	if err := db.First(&user, "id = ?", req.UserID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+	// Зона, ряд или полка - контейнеры: товар кладётся в ячейку внутри них
+	if targetLoc.Kind != locations.KindBin {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"error":   "Пользователь не найден",
+			"error":   "Товар хранится только в ячейке (bin), а " + targetLoc.Code + " - " + targetLoc.Kind,
 		})
 		return
 	}
 
-	// Сохраняем исходную локацию
-	fromLocationID := item.LocationID
+	// Автор перемещения — пользователь сессии, а не значение из тела запроса
+	if rejectForeignActor(c, req.UserID) {
+		return
+	}
+	userID := currentUserID(c)
 
-	// Обновляем lokacию товара
-	if err := db.Model(&item).Update("location_id", req.ToLocationID).Error; err != nil {
-		log.Printf("Ошибка при обновлении локации товара: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
+	// Исходная локация: явно указанная или основная локация товара
+	fromLocationID := req.FromLocationID
+	if fromLocationID == "" {
+		fromLocationID = item.LocationID
+	}
+	if fromLocationID == req.ToLocationID {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"error":   "Ошибка при обновлении локации товара",
+			"error":   "Исходная и целевая локации совпадают",
 		})
 		return
 	}
 
-	// Создаём запись в истории перемещений
-	movement := models.ItemMovement{
-		ItemID:         req.ItemID,
-		FromLocationID: fromLocationID,
-		ToLocationID:   req.ToLocationID,
-		UserID:         req.UserID,
-		Notes:          req.Notes,
-		MovedAt:        time.Now(),
-	}
+	// Остатки, основная локация и запись в истории меняются одной транзакцией
+	var movement models.ItemMovement
+	var violations []locations.Violation
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := inventory.LockItem(tx, &item); err != nil {
+			return err
+		}
+
+		available, err := inventory.Balance(tx, item.ID, fromLocationID)
+		if err != nil {
+			return err
+		}
+
+		qty := req.Quantity
+		if qty == 0 {
+			qty = available
+		}
+		if qty == 0 {
+			return inventory.ErrInsufficientStock
+		}
+
+		// Вместимость и категории ячейки и её полки/зоны
+		violations, err = locations.CheckPlacement(tx, targetLoc, fromLocationID, item, qty)
+		if err != nil {
+			return err
+		}
+		if len(violations) > 0 && locations.RejectOverCapacity() {
+			return locations.ErrOverCapacity
+		}
+
+		if err := inventory.Move(tx, item.ID, fromLocationID, req.ToLocationID, qty); err != nil {
+			return err
+		}
+
+		// Если с основной локации забрали всё — основной становится целевая
+		if fromLocationID == item.LocationID && qty == available {
+			if err := tx.Model(&item).Update("location_id", req.ToLocationID).Error; err != nil {
+				return err
+			}
+		}
+
+		// Создаём запись в истории перемещений
+		movement = models.ItemMovement{
+			ItemID:         req.ItemID,
+			FromLocationID: fromLocationID,
+			ToLocationID:   req.ToLocationID,
+			Quantity:       qty,
+			UserID:         userID,
+			Notes:          req.Notes,
+			MovedAt:        time.Now(),
+		}
+		return tx.Create(&movement).Error
+	})
 
-	if err := db.Create(&movement).Error; err != nil {
-		log.Printf("Ошибка при создании записи в истории: %v", err)
+	if errors.Is(err, inventory.ErrInsufficientStock) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Недостаточно товара на исходной локации (%s)", fromLocationID),
+		})
+		return
+	}
+	if errors.Is(err, locations.ErrOverCapacity) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success":    false,
+			"error":      "Локация переполнена или не подходит для товара: " + violations[0].Message,
+			"violations": violations,
+		})
+		return
+	}
+	if errors.Is(err, inventory.ErrConcurrentModification) {
+		respondConcurrentModification(c)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при перемещении товара %s: %v", req.ItemID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"error":   "Ошибка при записи в историю",
+			"error":   "Ошибка при перемещении товара",
 		})
 		return
 	}
 
-	log.Printf("✓ Товар %s перемещён из %s в %s (оператор: %s)",
-		req.ItemID, fromLocationID, req.ToLocationID, req.UserID)
+	log.Printf("✓ Товар %s: %d шт. перемещено из %s в %s (оператор: %s)",
+		req.ItemID, movement.Quantity, fromLocationID, req.ToLocationID, userID)
+
+	balances, _ := inventory.Balances(db, item)
 
 	c.JSON(http.StatusOK, MoveResponse{
 		Success:  true,
 		Message:  "Товар успешно перемещён",
 		Movement: &movement,
+		Balances: balances,
+		Warnings: violations,
+	})
+}
+
+// respondConcurrentModification - 409, когда товар изменён параллельным запросом
+func respondConcurrentModification(c *gin.Context) {
+	c.JSON(http.StatusConflict, gin.H{
+		"success": false,
+		"error":   "Товар был изменён другим пользователем, обновите данные и повторите",
 	})
 }
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"QR-GENERATOR/internal/database"
+	"QR-GENERATOR/internal/inventory"
+	"QR-GENERATOR/internal/locations"
+	"QR-GENERATOR/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PutawaySuggest GET /api/putaway/suggest?item_id=&qty=
+// Подбирает ячейки для размещения qty единиц товара: с учётом свободного
+// места (ограничения ячейки и её полки/зоны), близости к уже лежащему товару
+// и зон, отведённых под категорию. &under=<id> - искать только в локации
+// (складе, зоне), &limit=5 - число вариантов.
+func PutawaySuggest(c *gin.Context) {
+	db := database.GetDB()
+
+	qty, err := strconv.Atoi(c.DefaultQuery("qty", "1"))
+	if err != nil || qty < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "qty должно быть положительным числом"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 5
+	}
+
+	var item models.Item
+	if err := db.First(&item, "id = ?", c.Query("item_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Товар не найден"})
+		return
+	}
+
+	var list []models.Location
+	if err := db.Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	bins, err := locations.BinUsage(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	balances, err := inventory.Balances(db, item)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	var stock []string
+	for _, b := range balances {
+		if b.LocationID != "" && b.Quantity > 0 {
+			stock = append(stock, b.LocationID)
+		}
+	}
+
+	suggestions := locations.Suggest(list, locations.RollUp(list, bins), item, qty, stock)
+	if under := c.Query("under"); under != "" {
+		var root models.Location
+		if err := db.First(&root, "id = ?", under).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Локация не найдена"})
+			return
+		}
+		filtered := suggestions[:0]
+		for _, s := range suggestions {
+			if strings.HasPrefix(s.Location.Path, root.Path) {
+				filtered = append(filtered, s)
+			}
+		}
+		suggestions = filtered
+	}
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"item_id":     item.ID,
+		"quantity":    qty,
+		"suggestions": suggestions,
+	})
+}
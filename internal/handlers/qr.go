@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"QR-GENERATOR/internal/database"
+	"QR-GENERATOR/internal/models"
+	"QR-GENERATOR/internal/qr"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// qrResolver находит объект этикетки и возвращает его краткое название
+type qrResolver func(db *gorm.DB, id string) (string, error)
+
+// qrResolvers - поиск объектов по типу этикетки. Новый тип объекта
+// регистрируется в qr.RegisterType и добавляется сюда.
+var qrResolvers = map[qr.Type]qrResolver{
+	qr.TypeItem: func(db *gorm.DB, id string) (string, error) {
+		var item models.Item
+		err := db.Select("id", "name").First(&item, "id = ?", id).Error
+		return item.Name, err
+	},
+	qr.TypeLocation: func(db *gorm.DB, id string) (string, error) {
+		var loc models.Location
+		err := db.Select("id", "code").First(&loc, "id = ?", id).Error
+		return loc.Code, err
+	},
+	qr.TypeWorkOrder: func(db *gorm.DB, id string) (string, error) {
+		var order models.WorkOrder
+		err := db.Select("id", "equipment").First(&order, "id = ?", id).Error
+		return order.Equipment, err
+	},
+	qr.TypeEquipment: func(db *gorm.DB, id string) (string, error) {
+		var eq models.Equipment
+		err := db.Select("id", "name", "license_plate").First(&eq, "id = ?", id).Error
+		return strings.TrimSpace(eq.Name + " " + eq.LicensePlate), err
+	},
+}
+
+// DecodeQRRequest - содержимое отсканированной этикетки
+type DecodeQRRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// DecodeQRResponse - результат проверки этикетки
+type DecodeQRResponse struct {
+	Success bool        `json:"success"`
+	Payload *qr.Payload `json:"payload,omitempty"`
+	Label   string      `json:"label,omitempty"` // название объекта для подтверждения на экране
+	Error   string      `json:"error,omitempty"`
+}
+
+// DecodeQR - обработчик POST /api/qr/decode
+// Проверяет формат и подпись этикетки и что объект всё ещё существует.
+// Сканеры отправляют сюда содержимое QR вместо разбора строки на клиенте.
+func DecodeQR(c *gin.Context) {
+	var req DecodeQRRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, DecodeQRResponse{Success: false, Error: "Невалидные данные: " + err.Error()})
+		return
+	}
+
+	resp, status := checkLabel(database.GetDB(), req.Content)
+	c.JSON(status, resp)
+}
+
+// checkLabel разбирает содержимое этикетки, проверяет подпись и находит объект.
+// Возвращает ответ и HTTP статус для него.
+func checkLabel(db *gorm.DB, content string) (DecodeQRResponse, int) {
+	payload, err := qr.Decode(content)
+	switch {
+	case errors.Is(err, qr.ErrBadSignature):
+		return DecodeQRResponse{Success: false, Error: "Подпись этикетки недействительна (подделка или устаревший ключ)"}, http.StatusUnprocessableEntity
+	case errors.Is(err, qr.ErrUnsigned):
+		return DecodeQRResponse{Success: false, Payload: &payload, Error: "Этикетка без подписи, перепечатайте её"}, http.StatusUnprocessableEntity
+	case errors.Is(err, qr.ErrUnsupportedVersion):
+		return DecodeQRResponse{Success: false, Error: "Неподдерживаемая версия этикетки"}, http.StatusUnprocessableEntity
+	case err != nil:
+		return DecodeQRResponse{Success: false, Error: "Неизвестный формат QR"}, http.StatusBadRequest
+	}
+
+	resolve, ok := qrResolvers[payload.Type]
+	if !ok {
+		return DecodeQRResponse{Success: true, Payload: &payload}, http.StatusOK
+	}
+
+	label, err := resolve(db, payload.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DecodeQRResponse{Success: false, Payload: &payload, Error: "Объект этикетки не найден (удалён или этикетка устарела)"}, http.StatusNotFound
+	}
+	if err != nil {
+		return DecodeQRResponse{Success: false, Error: err.Error()}, http.StatusInternalServerError
+	}
+
+	return DecodeQRResponse{Success: true, Payload: &payload, Label: label}, http.StatusOK
+}
+
+// maxZipItems - ограничение на один ZIP с QR
+const maxZipItems = 5000
+
+// AdminGetItemsQRZip GET /api/admin/items/qr.zip — ZIP с QR товаров по фильтрам
+// списка (search, category) и manifest.csv. Изображения генерируются в памяти,
+// qrcodes/ не используется. Параметры изображения - как у AdminGetItemQR.
+func AdminGetItemsQRZip(c *gin.Context) {
+	opts, err := qr.ParseOptions(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	var items []models.Item
+	if err := filterItems(db.Preload("Location"), c).Order("sku").Limit(maxZipItems + 1).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Товары не найдены"})
+		return
+	}
+	if len(items) > maxZipItems {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": fmt.Sprintf("Слишком много товаров (максимум %d), уточните фильтр", maxZipItems)})
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=items_qr_%s.zip", time.Now().Format("20060102_150405")))
+	c.Status(http.StatusOK)
+
+	// Ответ уже начат - ошибки дальше только логируются, архив будет оборван
+	zw := zip.NewWriter(c.Writer)
+	var manifest strings.Builder
+	manifest.WriteString("\uFEFF") // BOM, чтобы Excel открыл кириллицу
+	mw := csv.NewWriter(&manifest)
+	mw.Write([]string{"file", "id", "sku", "name", "part_number", "category", "location", "qr_content", "error"})
+
+	used := make(map[string]bool, len(items))
+	for _, item := range items {
+		name := zipEntryName(item.SKU, item.ID, opts.Ext(), used)
+		location := ""
+		if item.Location != nil {
+			location = item.Location.Code
+		}
+
+		content, data, err := renderItemQR(item.ID, opts)
+		if err != nil {
+			log.Printf("⚠️  Ошибка генерации QR товара %s: %v", item.ID, err)
+			mw.Write([]string{"", item.ID, item.SKU, item.Name, item.PartNumber, item.Category, location, content, err.Error()})
+			continue
+		}
+		w, err := zw.Create(name)
+		if err == nil {
+			_, err = w.Write(data)
+		}
+		if err != nil {
+			log.Printf("⚠️  Обрыв ZIP с QR: %v", err)
+			return
+		}
+		mw.Write([]string{name, item.ID, item.SKU, item.Name, item.PartNumber, item.Category, location, content, ""})
+	}
+
+	mw.Flush()
+	w, err := zw.Create("manifest.csv")
+	if err == nil {
+		_, err = w.Write([]byte(manifest.String()))
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		log.Printf("⚠️  Обрыв ZIP с QR: %v", err)
+	}
+}
+
+func renderItemQR(id string, opts qr.RenderOptions) (string, []byte, error) {
+	content, err := qr.Encode(qr.TypeItem, id)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := qr.Render(content, opts)
+	return content, data, err
+}
+
+// zipEntryName - имя файла в архиве по SKU (без символов, недопустимых
+// в именах файлов); пустой или повторившийся SKU дополняется ID товара
+func zipEntryName(sku, id, ext string, used map[string]bool) string {
+	base := strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(sku))
+	if base == "" || base == "." || base == ".." {
+		base = id
+	}
+	name := base + ext
+	if used[name] {
+		name = base + "_" + id + ext
+	}
+	used[name] = true
+	return name
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"QR-GENERATOR/internal/database"
+	"QR-GENERATOR/internal/models"
+	"QR-GENERATOR/internal/qr"
+	"QR-GENERATOR/internal/scan"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxScanUpload - максимальный размер загружаемого фото
+const maxScanUpload = 15 << 20
+
+// ScanCode - распознанный код и найденный по нему объект
+type ScanCode struct {
+	Format    string      `json:"format"`  // qr, ean13, ean8, upca, code128
+	Content   string      `json:"content"` // текст кода
+	Type      qr.Type     `json:"type,omitempty"`
+	ID        string      `json:"id,omitempty"`
+	Label     string      `json:"label,omitempty"`      // название объекта
+	MatchedBy string      `json:"matched_by,omitempty"` // label (наша этикетка), barcode, sku, part_number
+	Payload   *qr.Payload `json:"payload,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// ScanDecode - обработчик POST /api/scan/decode
+// Принимает фото (multipart, поле image), распознаёт QR и штрихкоды
+// EAN-13/EAN-8/UPC-A/Code 128 и находит по ним товар, локацию или заявку.
+// Наши этикетки проверяются как в /api/qr/decode, прочие коды ищутся
+// среди штрихкодов, SKU и номеров деталей товаров.
+func ScanDecode(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxScanUpload)
+	header, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Файл image не найден или больше 15 МБ"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Не удалось прочитать файл"})
+		return
+	}
+	defer file.Close()
+
+	codes, err := scan.DecodeImage(file)
+	switch {
+	case errors.Is(err, scan.ErrNotFound):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"success": false, "error": "Коды на изображении не найдены"})
+		return
+	case errors.Is(err, scan.ErrImageShape):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Изображение слишком вытянуто, сфотографируйте код ближе"})
+		return
+	case errors.Is(err, scan.ErrImage):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Нужно изображение JPEG, PNG или GIF до 50 Мпикс"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	results := make([]ScanCode, 0, len(codes))
+	for _, code := range codes {
+		result, err := resolveScanCode(db, code)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "codes": results})
+}
+
+// resolveScanCode находит объект по распознанному коду
+func resolveScanCode(db *gorm.DB, code scan.Code) (ScanCode, error) {
+	result := ScanCode{Format: code.Format, Content: code.Text}
+
+	// Наша этикетка (в том числе старого формата ITEM:/LOC:/WO:)
+	if _, err := qr.Decode(code.Text); !errors.Is(err, qr.ErrMalformed) && !errors.Is(err, qr.ErrUnknownType) {
+		resp, status := checkLabel(db, code.Text)
+		if status == http.StatusInternalServerError {
+			return result, errors.New(resp.Error)
+		}
+		result.Payload = resp.Payload
+		result.Label = resp.Label
+		result.Error = resp.Error
+		if resp.Success {
+			result.Type, result.ID, result.MatchedBy = resp.Payload.Type, resp.Payload.ID, "label"
+		}
+		return result, nil
+	}
+
+	// Заводской штрихкод (EAN/UPC/GS1) из справочника штрихкодов
+	if itemID, err := findItemIDByBarcode(db, code.Text); err == nil {
+		var item models.Item
+		if err := db.Select("id", "name").First(&item, "id = ?", itemID).Error; err == nil {
+			result.Type, result.ID, result.Label, result.MatchedBy = qr.TypeItem, item.ID, item.Name, "barcode"
+			return result, nil
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return result, err
+	}
+
+	// Код поставщика или SKU, напечатанный штрихкодом
+	for _, field := range []string{"sku", "part_number"} {
+		var item models.Item
+		err := db.Select("id", "name").Where(field+" = ?", code.Text).First(&item).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Type, result.ID, result.Label, result.MatchedBy = qr.TypeItem, item.ID, item.Name, field
+		return result, nil
+	}
+
+	result.Error = "Объект с таким кодом не найден"
+	return result, nil
+}
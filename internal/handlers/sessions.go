@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"QR-GENERATOR/internal/auth"
+	"QR-GENERATOR/internal/database"
+	"QR-GENERATOR/internal/middleware"
+	"QR-GENERATOR/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// События журнала auth_events
+const (
+	EventLoginSuccess   = "login_success"
+	EventLoginFailure   = "login_failure"
+	EventLoginBlocked   = "login_blocked"
+	EventLogout         = "logout"
+	EventTokenRefresh   = "token_refresh"
+	EventSessionRevoked = "session_revoked"
+)
+
+// recordAuthEvent пишет событие в auth_events. Ошибка записи не прерывает запрос.
+func recordAuthEvent(c *gin.Context, event, userID, username, sessionID, details string) {
+	ev := models.AuthEvent{
+		Event:     event,
+		UserID:    userID,
+		Username:  username,
+		SessionID: sessionID,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Details:   details,
+		CreatedAt: time.Now(),
+	}
+	if err := database.GetDB().Create(&ev).Error; err != nil {
+		log.Printf("⚠️  Ошибка записи auth_event %s: %v", event, err)
+	}
+}
+
+// startSession создаёт запись сессии и выпускает для неё токен
+func startSession(c *gin.Context, user models.User) (string, time.Time, models.Session, error) {
+	now := time.Now()
+	session := models.Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		ExpiresAt:  auth.TokenExpiry(),
+		LastSeenAt: now,
+		CreatedAt:  now,
+	}
+	if err := database.GetDB().Create(&session).Error; err != nil {
+		return "", time.Time{}, session, err
+	}
+
+	token, err := auth.IssueToken(user, session.ID, session.ExpiresAt)
+	if err != nil {
+		return "", time.Time{}, session, err
+	}
+	return token, session.ExpiresAt, session, nil
+}
+
+// revokeSessions завершает активные сессии, подходящие под условие
+func revokeSessions(revokedBy string, query interface{}, args ...interface{}) (int64, error) {
+	result := database.GetDB().Model(&models.Session{}).
+		Where("revoked_at IS NULL").
+		Where(query, args...).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_by": revokedBy})
+	return result.RowsAffected, result.Error
+}
+
+// currentSessionID - ID сессии из токена запроса
+func currentSessionID(c *gin.Context) string {
+	return c.GetString(middleware.ContextSessionID)
+}
+
+// Logout POST /api/logout — завершает текущую сессию
+func Logout(c *gin.Context) {
+	sessionID := currentSessionID(c)
+	if _, err := revokeSessions(currentUserID(c), "id = ?", sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	recordAuthEvent(c, EventLogout, currentUserID(c), c.GetString(middleware.ContextUsername), sessionID, "")
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// RefreshToken POST /api/refresh — выдаёт новый токен и завершает текущую сессию
+func RefreshToken(c *gin.Context) {
+	db := database.GetDB()
+	var user models.User
+	if err := db.First(&user, "id = ?", currentUserID(c)).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Пользователь не найден"})
+		return
+	}
+
+	oldSessionID := currentSessionID(c)
+	token, expiresAt, session, err := startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Ошибка при создании токена"})
+		return
+	}
+	revokeSessions(user.ID, "id = ?", oldSessionID)
+
+	recordAuthEvent(c, EventTokenRefresh, user.ID, user.Username, session.ID, "previous session "+oldSessionID)
+	c.JSON(http.StatusOK, gin.H{"success": true, "token": token, "expires_at": expiresAt})
+}
+
+// AdminGetSessions GET /api/admin/sessions?user_id=&all=true
+// По умолчанию — только активные (не отозванные и не истёкшие) сессии
+func AdminGetSessions(c *gin.Context) {
+	db := database.GetDB()
+
+	query := db.Preload("User").Order("last_seen_at DESC")
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if c.Query("all") != "true" {
+		query = query.Where("revoked_at IS NULL AND expires_at > ?", time.Now())
+	}
+
+	var sessions []models.Session
+	if err := query.Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "sessions": sessions})
+}
+
+// AdminRevokeSession DELETE /api/admin/sessions/:id — завершить одну сессию
+func AdminRevokeSession(c *gin.Context) {
+	id := c.Param("id")
+
+	var session models.Session
+	if err := database.GetDB().First(&session, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Сессия не найдена"})
+		return
+	}
+
+	revoked, err := revokeSessions(currentUserID(c), "id = ?", id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if revoked > 0 {
+		recordAuthEvent(c, EventSessionRevoked, session.UserID, "", session.ID, "revoked by "+currentUserID(c))
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "revoked": revoked})
+}
+
+// AdminRevokeUserSessions DELETE /api/admin/users/:id/sessions — завершить все сессии пользователя
+// (например, потерян планшет-сканер)
+func AdminRevokeUserSessions(c *gin.Context) {
+	userID := c.Param("id")
+
+	revoked, err := revokeSessions(currentUserID(c), "user_id = ?", userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if revoked > 0 {
+		recordAuthEvent(c, EventSessionRevoked, userID, "", "", strconv.FormatInt(revoked, 10)+" sessions revoked by "+currentUserID(c))
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "revoked": revoked})
+}
+
+// AdminGetAuthEvents GET /api/admin/auth-events?user_id=&username=&event=&limit=
+func AdminGetAuthEvents(c *gin.Context) {
+	db := database.GetDB()
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	query := db.Order("created_at DESC").Limit(limit)
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if username := c.Query("username"); username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+
+	var events []models.AuthEvent
+	if err := query.Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "events": events})
+}
@@ -2,14 +2,28 @@ package handlers
 
 import (
 	"QR-GENERATOR/internal/database"
+	"QR-GENERATOR/internal/gs1"
+	"QR-GENERATOR/internal/inventory"
 	"QR-GENERATOR/internal/models"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// errAlreadyReceived - поставка уже принята на склад
+var errAlreadyReceived = errors.New("supply request already received")
+
+// errSupplyItemMissing, errSupplyBadQuantity - поставку нечем оприходовать:
+// заявка остаётся открытой, а не закрывается без прихода на склад
+var (
+	errSupplyItemMissing = errors.New("supply request item not found")
+	errSupplyBadQuantity = errors.New("supply request quantity must be positive")
+)
+
 // var DB = database.DB // если у тебя так подключено
 func CreateSupplyRequest(c *gin.Context) {
 	db := database.GetDB()
@@ -20,7 +34,7 @@ func CreateSupplyRequest(c *gin.Context) {
 		ItemID   string `json:"item_id"`
 		Quantity int    `json:"quantity"`
 		Reason   string `json:"reason"`
-		UserID   string `json:"user_id"`
+		UserID   string `json:"user_id"` // устарело: автор берётся из сессии
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -28,10 +42,14 @@ func CreateSupplyRequest(c *gin.Context) {
 		return
 	}
 
+	if rejectForeignActor(c, input.UserID) {
+		return
+	}
+
 	req := models.SupplyRequest{
 		ID:          uuid.New().String(),
 		ItemID:      input.ItemID,
-		RequestedBy: input.UserID,
+		RequestedBy: currentUserID(c),
 		Quantity:    input.Quantity,
 		Reason:      input.Reason,
 		Status:      "created",
@@ -147,10 +165,34 @@ func ApproveByCommercial(c *gin.Context) {
 	c.JSON(200, gin.H{"success": true})
 }
 
+// ReceiveSupplyRequest - необязательные данные приёмки
+type ReceiveSupplyRequest struct {
+	// Barcode - штрихкод с упаковки. GTIN привязывается к товару, из GS1-128
+	// берутся партия (AI 10) и срок годности (AI 17).
+	Barcode string `json:"barcode"`
+}
+
 func ReceiveSupply(c *gin.Context) {
 	db := database.GetDB()
 	id := c.Param("id")
 
+	var body ReceiveSupplyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	// Опечатка или повреждённый код не должны молча терять партию и срок
+	var scanned gs1.Data
+	if body.Barcode != "" {
+		var err error
+		if scanned, err = gs1.ScanCode(body.Barcode); err != nil {
+			c.JSON(400, gin.H{"error": "invalid barcode: " + err.Error()})
+			return
+		}
+	}
+
 	var req models.SupplyRequest
 
 	if err := db.First(&req, "id = ?", id).Error; err != nil {
@@ -158,19 +200,81 @@ func ReceiveSupply(c *gin.Context) {
 		return
 	}
 
-	// увеличиваем количество товара
-	db.Model(&models.Item{}).
-		Where("id = ?", req.ItemID).
-		Update("quantity", gorm.Expr("quantity + ?", req.Quantity))
+	// статус и остаток меняются вместе: повторная приёмка не увеличит остаток дважды
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if req.Status == "received" {
+			return errAlreadyReceived
+		}
+		if req.Quantity <= 0 {
+			return errSupplyBadQuantity
+		}
+		var item models.Item
+		if err := tx.First(&item, "id = ?", req.ItemID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errSupplyItemMissing
+			}
+			return err
+		}
+
+		result := tx.Model(&models.SupplyRequest{}).
+			Where("id = ? AND status <> ?", id, "received").
+			Update("status", "received")
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errAlreadyReceived
+		}
+
+		// увеличиваем остаток товара на его основной локации
+		if err := inventory.LockItem(tx, &item); err != nil {
+			return err
+		}
+		if scanned.GTIN != "" {
+			if _, err := attachBarcode(tx, item.ID, scanned.GTIN); err != nil {
+				return err
+			}
+		}
+		if scanned.Batch != "" {
+			now := time.Now()
+			if err := tx.Model(&item).Updates(map[string]interface{}{
+				"batch_number":     scanned.Batch,
+				"batch_quantity":   req.Quantity,
+				"batch_arrived_at": &now,
+				"batch_expires_at": scanned.Expiry,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return inventory.AddStock(tx, item.ID, item.LocationID, req.Quantity)
+	})
 
-	// меняем статус
-	db.Model(&models.SupplyRequest{}).
-		Where("id = ?", id).
-		Update("status", "received")
+	switch {
+	case errors.Is(err, errAlreadyReceived):
+		c.JSON(409, gin.H{"error": "request already received"})
+		return
+	case errors.Is(err, errSupplyBadQuantity):
+		c.JSON(400, gin.H{"error": "request quantity must be positive"})
+		return
+	case errors.Is(err, errSupplyItemMissing):
+		c.JSON(404, gin.H{"error": "requested item not found"})
+		return
+	case errors.Is(err, inventory.ErrConcurrentModification):
+		c.JSON(409, gin.H{"error": "item was modified concurrently, retry"})
+		return
+	case errors.Is(err, errBarcodeTaken):
+		c.JSON(409, gin.H{"error": "barcode belongs to another item"})
+		return
+	case err != nil:
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(200, gin.H{
 		"success": true,
 		"message": "Товар принят на склад",
+		"batch":   scanned.Batch,
+		"expiry":  scanned.Expiry,
 	})
 }
 
@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"QR-GENERATOR/internal/auth"
+	"QR-GENERATOR/internal/database"
+	"QR-GENERATOR/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================================================
+// USERS (управление пользователями, только admin)
+// ============================================================================
+
+// CreateUserRequest - поля для создания пользователя
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8"`
+	Role     string `json:"role" binding:"required"`
+}
+
+// UpdateUserRequest - изменяемые поля пользователя
+type UpdateUserRequest struct {
+	Email string `json:"email" binding:"omitempty,email"`
+	Role  string `json:"role"`
+}
+
+// ResetPasswordRequest - новый пароль (если пусто — генерируется временный)
+type ResetPasswordRequest struct {
+	Password string `json:"password" binding:"omitempty,min=8"`
+}
+
+// ChangePasswordRequest - смена своего пароля
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
+// AdminCreateUser POST /api/admin/users
+// Новый пользователь обязан сменить пароль при первом входе
+func AdminCreateUser(c *gin.Context) {
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if !auth.IsValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Неизвестная роль", "roles": auth.Roles()})
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Логин не может быть пустым"})
+		return
+	}
+
+	db := database.GetDB()
+
+	// Логин и email уникальны, в том числе среди отключённых пользователей
+	var count int64
+	db.Unscoped().Model(&models.User{}).
+		Where("username = ? OR email = ?", req.Username, req.Email).
+		Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Пользователь с таким логином или email уже существует"})
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Ошибка хеширования пароля"})
+		return
+	}
+
+	user := models.User{
+		ID:                 "user_" + uuid.New().String()[:8],
+		Username:           req.Username,
+		Email:              req.Email,
+		PasswordHash:       hash,
+		Role:               req.Role,
+		MustChangePassword: true,
+		CreatedAt:          time.Now(),
+		UpdatedAt:          time.Now(),
+	}
+
+	if err := db.Create(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "user": user})
+}
+
+// AdminGetUsers GET /api/admin/users?search=&role=&status=active|disabled|all
+func AdminGetUsers(c *gin.Context) {
+	db := database.GetDB()
+
+	search := c.Query("search")
+	role := c.Query("role")
+	status := c.DefaultQuery("status", "active")
+
+	query := db.Order("username ASC")
+	switch status {
+	case "disabled":
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	case "all":
+		query = query.Unscoped()
+	}
+
+	if search != "" {
+		like := "%" + strings.ToLower(search) + "%"
+		query = query.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ?", like, like)
+	}
+	if role != "" {
+		query = query.Where("role = ?", role)
+	}
+
+	var users []models.User
+	if err := query.Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "users": users, "roles": auth.Roles()})
+}
+
+// AdminUpdateUser PUT /api/admin/users/:id — email и роль
+func AdminUpdateUser(c *gin.Context) {
+	id := c.Param("id")
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Пользователь не найден"})
+		return
+	}
+
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if req.Role != "" {
+		if !auth.IsValidRole(req.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Неизвестная роль", "roles": auth.Roles()})
+			return
+		}
+		// Не даём администратору случайно лишить себя прав
+		if user.ID == currentUserID(c) && req.Role != auth.RoleAdmin {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Нельзя изменить собственную роль"})
+			return
+		}
+		user.Role = req.Role
+	}
+	if email := strings.TrimSpace(req.Email); email != "" && email != user.Email {
+		var count int64
+		db.Unscoped().Model(&models.User{}).
+			Where("email = ? AND id <> ?", email, user.ID).
+			Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Пользователь с таким email уже существует"})
+			return
+		}
+		user.Email = email
+	}
+	user.UpdatedAt = time.Now()
+
+	if err := db.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "user": user})
+}
+
+// AdminDisableUser DELETE /api/admin/users/:id — мягкое удаление (DeletedAt)
+func AdminDisableUser(c *gin.Context) {
+	id := c.Param("id")
+
+	if id == currentUserID(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Нельзя отключить самого себя"})
+		return
+	}
+
+	db := database.GetDB()
+	result := db.Delete(&models.User{}, "id = ?", id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Пользователь не найден"})
+		return
+	}
+	revokeSessions(currentUserID(c), "user_id = ?", id)
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// AdminRestoreUser POST /api/admin/users/:id/restore — включить отключённого пользователя
+func AdminRestoreUser(c *gin.Context) {
+	id := c.Param("id")
+	db := database.GetDB()
+
+	result := db.Unscoped().Model(&models.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{"deleted_at": nil, "updated_at": time.Now()})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Отключённый пользователь не найден"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// AdminResetUserPassword POST /api/admin/users/:id/reset-password
+// Устанавливает новый (или сгенерированный) пароль и требует сменить его при входе
+func AdminResetUserPassword(c *gin.Context) {
+	id := c.Param("id")
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Пользователь не найден"})
+		return
+	}
+
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	password := req.Password
+	generated := password == ""
+	if generated {
+		var err error
+		if password, err = generateTempPassword(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Ошибка генерации пароля"})
+			return
+		}
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Ошибка хеширования пароля"})
+		return
+	}
+
+	if err := db.Model(&user).Updates(map[string]interface{}{
+		"password_hash":        hash,
+		"must_change_password": true,
+		"updated_at":           time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	// Сбрасываем все сессии, чтобы старый пароль/токен больше не работал
+	revokeSessions(currentUserID(c), "user_id = ?", user.ID)
+
+	resp := gin.H{"success": true, "must_change_password": true}
+	if generated {
+		resp["temporary_password"] = password
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// AdminUnlockUser POST /api/admin/users/:id/unlock — снять блокировку входа
+func AdminUnlockUser(c *gin.Context) {
+	id := c.Param("id")
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.Unscoped().First(&user, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Пользователь не найден"})
+		return
+	}
+
+	// IP, с которых недавно подбирали пароль к этой учётке, тоже разблокируем:
+	// иначе пользователь за тем же NAT остаётся заблокированным по IP.
+	ips := []string{}
+	db.Model(&models.AuthEvent{}).
+		Where("username = ? AND event IN ? AND created_at > ?",
+			user.Username, []string{EventLoginFailure, EventLoginBlocked},
+			time.Now().Add(-loginGuard.IPWindow())).
+		Distinct().Pluck("ip", &ips)
+
+	attempts, found := loginGuard.Status(user.Username)
+	if err := loginGuard.Unlock(user.Username, ips...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Ошибка снятия блокировки"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":         true,
+		"was_locked":      found && time.Now().Before(attempts.LockedUntil),
+		"failed_attempts": attempts.Failures,
+		"unlocked_ips":    ips,
+	})
+}
+
+// ChangePassword POST /api/password — смена своего пароля.
+// Снимает флаг must_change_password и выдаёт новый токен.
+func ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if req.OldPassword == req.NewPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Новый пароль должен отличаться от старого"})
+		return
+	}
+
+	db := database.GetDB()
+	var user models.User
+	if err := db.First(&user, "id = ?", currentUserID(c)).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Пользователь не найден"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		}
+		return
+	}
+
+	if ok, _ := auth.CheckPassword(user.PasswordHash, req.OldPassword); !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Неверный текущий пароль"})
+		return
+	}
+
+	hash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Ошибка хеширования пароля"})
+		return
+	}
+
+	user.PasswordHash = hash
+	user.MustChangePassword = false
+	user.UpdatedAt = time.Now()
+	if err := db.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	// Старые сессии (в том числе текущая) больше не действуют
+	revokeSessions(user.ID, "user_id = ?", user.ID)
+	token, expiresAt, _, err := startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Ошибка при создании токена"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "token": token, "expires_at": expiresAt})
+}
+
+// generateTempPassword - случайный временный пароль (12 символов)
+func generateTempPassword() (string, error) {
+	buf := make([]byte, 9)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
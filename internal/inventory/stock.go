@@ -0,0 +1,187 @@
+// Package inventory - операции с остатками товаров по локациям.
+//
+// Инвариант: items.quantity == SUM(stock_balances.quantity) по товару.
+// Остаток с пустым location_id — товар принят, но ещё не размещён на полку.
+// Все функции принимают *gorm.DB, чтобы вызываться внутри транзакции.
+//
+// Конкурентные изменения: перед изменением остатков товар блокируется через
+// LockItem — условное увеличение items.version. Если товар успел изменить
+// другой запрос, LockItem возвращает ErrConcurrentModification, и вызывающий
+// откатывает транзакцию (в API это 409).
+package inventory
+
+import (
+	"errors"
+	"time"
+
+	"QR-GENERATOR/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInsufficientStock - на локации меньше, чем требуется
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrInvalidQuantity - количество должно быть положительным
+	ErrInvalidQuantity = errors.New("invalid quantity")
+	// ErrConcurrentModification - товар изменён другим запросом после чтения
+	ErrConcurrentModification = errors.New("item was modified concurrently")
+)
+
+// LockItem увеличивает версию товара, если она не изменилась с момента чтения.
+// UPDATE берёт блокировку строки до конца транзакции, поэтому параллельные
+// изменения того же товара выполняются по очереди, а опоздавший получает
+// ErrConcurrentModification. При успехе item.Version обновляется.
+func LockItem(tx *gorm.DB, item *models.Item) error {
+	result := tx.Model(&models.Item{}).
+		Where("id = ? AND version = ?", item.ID, item.Version).
+		UpdateColumn("version", gorm.Expr("version + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrConcurrentModification
+	}
+	item.Version++
+	return nil
+}
+
+// Balance возвращает остаток товара на локации (0, если записи нет)
+func Balance(tx *gorm.DB, itemID, locationID string) (int, error) {
+	var b models.StockBalance
+	err := tx.Where("item_id = ? AND location_id = ?", itemID, locationID).First(&b).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return b.Quantity, err
+}
+
+// Balances возвращает все ненулевые остатки товара, начиная с основной локации
+func Balances(tx *gorm.DB, item models.Item) ([]models.StockBalance, error) {
+	var balances []models.StockBalance
+	err := tx.Preload("Location").
+		Where("item_id = ? AND quantity <> 0", item.ID).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "CASE WHEN location_id = ? THEN 0 ELSE 1 END, quantity DESC",
+			Vars: []interface{}{item.LocationID},
+		}}).
+		Find(&balances).Error
+	return balances, err
+}
+
+// AddStock увеличивает остаток на локации и общий остаток товара
+func AddStock(tx *gorm.DB, itemID, locationID string, qty int) error {
+	if qty <= 0 {
+		return ErrInvalidQuantity
+	}
+	if err := changeBalance(tx, itemID, locationID, qty); err != nil {
+		return err
+	}
+	return tx.Model(&models.Item{}).Where("id = ?", itemID).
+		UpdateColumn("quantity", gorm.Expr("quantity + ?", qty)).Error
+}
+
+// RemoveStock уменьшает остаток на локации; не даёт уйти в минус
+func RemoveStock(tx *gorm.DB, itemID, locationID string, qty int) error {
+	if qty <= 0 {
+		return ErrInvalidQuantity
+	}
+	have, err := Balance(tx, itemID, locationID)
+	if err != nil {
+		return err
+	}
+	if have < qty {
+		return ErrInsufficientStock
+	}
+	if err := changeBalance(tx, itemID, locationID, -qty); err != nil {
+		return err
+	}
+	return tx.Model(&models.Item{}).Where("id = ?", itemID).
+		UpdateColumn("quantity", gorm.Expr("quantity - ?", qty)).Error
+}
+
+// Allocation - сколько списано с какой локации
+type Allocation struct {
+	LocationID string `json:"location_id"`
+	Quantity   int    `json:"quantity"`
+}
+
+// Consume списывает qty со всех локаций товара (сначала с основной).
+// Возвращает распределение по локациям и недостачу, если остатков не хватило.
+func Consume(tx *gorm.DB, item models.Item, qty int) ([]Allocation, int, error) {
+	if qty <= 0 {
+		return nil, 0, ErrInvalidQuantity
+	}
+	balances, err := Balances(tx, item)
+	if err != nil {
+		return nil, qty, err
+	}
+
+	var allocations []Allocation
+	remaining := qty
+	for _, b := range balances {
+		if remaining == 0 {
+			break
+		}
+		if b.Quantity <= 0 {
+			continue
+		}
+		take := b.Quantity
+		if take > remaining {
+			take = remaining
+		}
+		if err := RemoveStock(tx, item.ID, b.LocationID, take); err != nil {
+			return allocations, remaining, err
+		}
+		allocations = append(allocations, Allocation{LocationID: b.LocationID, Quantity: take})
+		remaining -= take
+	}
+	return allocations, remaining, nil
+}
+
+// Move переносит qty единиц товара между локациями. Общий остаток не меняется.
+func Move(tx *gorm.DB, itemID, fromLocationID, toLocationID string, qty int) error {
+	if qty <= 0 {
+		return ErrInvalidQuantity
+	}
+	have, err := Balance(tx, itemID, fromLocationID)
+	if err != nil {
+		return err
+	}
+	if have < qty {
+		return ErrInsufficientStock
+	}
+	if err := changeBalance(tx, itemID, fromLocationID, -qty); err != nil {
+		return err
+	}
+	return changeBalance(tx, itemID, toLocationID, qty)
+}
+
+// changeBalance добавляет delta к остатку (item, location), создавая запись при необходимости
+func changeBalance(tx *gorm.DB, itemID, locationID string, delta int) error {
+	b := models.StockBalance{
+		ItemID:     itemID,
+		LocationID: locationID,
+		Quantity:   delta,
+		UpdatedAt:  time.Now(),
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "item_id"}, {Name: "location_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"quantity":   gorm.Expr("stock_balances.quantity + ?", delta),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&b).Error
+}
+
+// BackfillBalances создаёт остатки для товаров, у которых их ещё нет
+// (данные до появления stock_balances: весь остаток на items.location_id)
+func BackfillBalances(db *gorm.DB) error {
+	return db.Exec(`
+		INSERT INTO stock_balances (item_id, location_id, quantity, updated_at)
+		SELECT i.id, COALESCE(i.location_id, ''), i.quantity, NOW()
+		FROM items i
+		WHERE i.deleted_at IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM stock_balances sb WHERE sb.item_id = i.id)`).Error
+}
@@ -0,0 +1,198 @@
+package labels
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"QR-GENERATOR/internal/models"
+	"QR-GENERATOR/internal/qr"
+
+	"gorm.io/gorm"
+)
+
+// Виды объектов для этикеток
+const (
+	KindItem      = "item"
+	KindLocation  = "location"
+	KindOrder     = "order"
+	KindEquipment = "equipment"
+)
+
+// ErrUnknownKind - неизвестный вид объекта
+var ErrUnknownKind = errors.New("type должен быть item, location, order или equipment")
+
+// ItemLabel - этикетка товара: название, SKU, номер детали, основная ячейка
+func ItemLabel(item models.Item) (Label, error) {
+	content, err := qr.Encode(qr.TypeItem, item.ID)
+	if err != nil {
+		return Label{}, err
+	}
+	l := Label{QRContent: content, Title: item.Name, Lines: []string{"SKU: " + item.SKU}}
+	if item.PartNumber != "" {
+		l.Lines = append(l.Lines, "P/N: "+item.PartNumber)
+	}
+	if item.Location != nil {
+		l.Lines = append(l.Lines, "Ячейка: "+item.Location.Code)
+	}
+	return l, nil
+}
+
+// LocationLabel - этикетка ячейки: код, описание, ряд/секция/полка
+func LocationLabel(loc models.Location) (Label, error) {
+	content, err := qr.Encode(qr.TypeLocation, loc.ID)
+	if err != nil {
+		return Label{}, err
+	}
+	l := Label{QRContent: content, Title: loc.Code}
+	if loc.Description != "" {
+		l.Lines = append(l.Lines, loc.Description)
+	}
+	var place []string
+	if loc.Row != "" {
+		place = append(place, "Ряд "+loc.Row)
+	}
+	if loc.Section != "" {
+		place = append(place, "Секция "+loc.Section)
+	}
+	if loc.Shelf != "" {
+		place = append(place, "Полка "+loc.Shelf)
+	}
+	if len(place) > 0 {
+		l.Lines = append(l.Lines, strings.Join(place, " · "))
+	}
+	return l, nil
+}
+
+// WorkOrderLabel - этикетка заявки механика: номер, техника, вид работ
+func WorkOrderLabel(order models.WorkOrder) (Label, error) {
+	content, err := qr.Encode(qr.TypeWorkOrder, order.ID)
+	if err != nil {
+		return Label{}, err
+	}
+	l := Label{QRContent: content, Title: order.ID}
+	if order.Equipment != "" {
+		l.Lines = append(l.Lines, strings.TrimSpace(order.Equipment+" "+order.EquipmentNumber))
+	}
+	if order.WorkType != "" {
+		l.Lines = append(l.Lines, order.WorkType)
+	}
+	if order.Priority == "urgent" {
+		l.Lines = append(l.Lines, "СРОЧНО")
+	}
+	return l, nil
+}
+
+// EquipmentLabel - наклейка на технику: название, гос/инв номер, тип и год
+func EquipmentLabel(eq models.Equipment) (Label, error) {
+	content, err := qr.Encode(qr.TypeEquipment, eq.ID)
+	if err != nil {
+		return Label{}, err
+	}
+	l := Label{QRContent: content, Title: eq.Name, Lines: []string{"№ " + eq.LicensePlate}}
+	var info []string
+	if eq.Type != "" {
+		info = append(info, eq.Type)
+	}
+	if eq.Year > 0 {
+		info = append(info, strconv.Itoa(eq.Year)+" г.")
+	}
+	if len(info) > 0 {
+		l.Lines = append(l.Lines, strings.Join(info, " · "))
+	}
+	return l, nil
+}
+
+// Load загружает объекты kind по ids (в их порядке) или все и строит этикетки
+func Load(db *gorm.DB, kind string, ids []string, all bool) ([]Label, error) {
+	var out []Label
+
+	switch kind {
+	case "", KindItem:
+		query := db.Preload("Location").Order("name")
+		if !all {
+			query = query.Where("id IN ?", ids)
+		}
+		var items []models.Item
+		if err := query.Find(&items).Error; err != nil {
+			return nil, err
+		}
+		for _, item := range orderByIDs(items, ids, func(i models.Item) string { return i.ID }) {
+			l, err := ItemLabel(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, l)
+		}
+	case KindLocation:
+		query := db.Order("code")
+		if !all {
+			query = query.Where("id IN ?", ids)
+		}
+		var locs []models.Location
+		if err := query.Find(&locs).Error; err != nil {
+			return nil, err
+		}
+		for _, loc := range orderByIDs(locs, ids, func(l models.Location) string { return l.ID }) {
+			l, err := LocationLabel(loc)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, l)
+		}
+	case KindOrder:
+		query := db.Order("created_at DESC")
+		if !all {
+			query = query.Where("id IN ?", ids)
+		}
+		var orders []models.WorkOrder
+		if err := query.Find(&orders).Error; err != nil {
+			return nil, err
+		}
+		for _, order := range orderByIDs(orders, ids, func(o models.WorkOrder) string { return o.ID }) {
+			l, err := WorkOrderLabel(order)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, l)
+		}
+	case KindEquipment:
+		query := db.Order("name")
+		if !all {
+			query = query.Where("id IN ?", ids)
+		}
+		var list []models.Equipment
+		if err := query.Find(&list).Error; err != nil {
+			return nil, err
+		}
+		for _, eq := range orderByIDs(list, ids, func(e models.Equipment) string { return e.ID }) {
+			l, err := EquipmentLabel(eq)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, l)
+		}
+	default:
+		return nil, ErrUnknownKind
+	}
+	return out, nil
+}
+
+// orderByIDs расставляет записи в порядке ids (порядок выбора в интерфейсе)
+func orderByIDs[T any](rows []T, ids []string, id func(T) string) []T {
+	if len(ids) == 0 {
+		return rows
+	}
+	byID := make(map[string]T, len(rows))
+	for _, r := range rows {
+		byID[id(r)] = r
+	}
+	out := make([]T, 0, len(rows))
+	for _, i := range ids {
+		if r, ok := byID[i]; ok {
+			out = append(out, r)
+			delete(byID, i)
+		}
+	}
+	return out
+}
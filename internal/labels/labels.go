@@ -0,0 +1,303 @@
+// Package labels - PDF листы этикеток: QR код плюс читаемый текст
+// (название, SKU, номер детали, код ячейки).
+//
+// Кириллице нужен TTF шрифт: путь берётся из LABEL_FONT, иначе ищется
+// DejaVuSans в стандартных каталогах (пакет fonts-dejavu / font-dejavu).
+package labels
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"QR-GENERATOR/internal/qr"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// ErrFontNotFound - не найден TTF шрифт с кириллицей
+var ErrFontNotFound = errors.New("labels: TTF font not found, set LABEL_FONT")
+
+// ErrUnknownLayout - раскладка не зарегистрирована
+var ErrUnknownLayout = errors.New("labels: unknown layout")
+
+// Layout - раскладка этикеток на странице (все размеры в мм)
+type Layout struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	PageWidth   float64 `json:"page_width"`
+	PageHeight  float64 `json:"page_height"`
+	Columns     int     `json:"columns"`
+	Rows        int     `json:"rows"`
+	LabelWidth  float64 `json:"label_width"`
+	LabelHeight float64 `json:"label_height"`
+	MarginLeft  float64 `json:"margin_left"`
+	MarginTop   float64 `json:"margin_top"`
+	GapX        float64 `json:"gap_x"`
+	GapY        float64 `json:"gap_y"`
+	Padding     float64 `json:"padding"` // отступ содержимого от края этикетки
+}
+
+// PerPage - этикеток на странице
+func (l Layout) PerPage() int {
+	return l.Columns * l.Rows
+}
+
+// vertical - QR сверху, текст снизу (высокие термоэтикетки)
+func (l Layout) vertical() bool {
+	return l.LabelHeight > l.LabelWidth*1.2
+}
+
+// Встроенные раскладки. Листы A4 - под стандартную самоклеящуюся бумагу,
+// термо - одна этикетка на страницу для принтеров этикеток.
+var layouts = map[string]Layout{
+	"a4-3x8": {
+		Name: "a4-3x8", Description: "A4, 24 этикетки 70×37 мм",
+		PageWidth: 210, PageHeight: 297, Columns: 3, Rows: 8,
+		LabelWidth: 70, LabelHeight: 37, MarginTop: 0.5, Padding: 3,
+	},
+	"a4-2x7": {
+		Name: "a4-2x7", Description: "A4, 14 этикеток 99.1×38.1 мм",
+		PageWidth: 210, PageHeight: 297, Columns: 2, Rows: 7,
+		LabelWidth: 99.1, LabelHeight: 38.1, MarginLeft: 4.65, MarginTop: 15.15, GapX: 2.5, Padding: 3,
+	},
+	"thermal-58x40": {
+		Name: "thermal-58x40", Description: "Термоэтикетка 58×40 мм",
+		PageWidth: 58, PageHeight: 40, Columns: 1, Rows: 1,
+		LabelWidth: 58, LabelHeight: 40, Padding: 2,
+	},
+	"thermal-100x150": {
+		Name: "thermal-100x150", Description: "Термоэтикетка 100×150 мм",
+		PageWidth: 100, PageHeight: 150, Columns: 1, Rows: 1,
+		LabelWidth: 100, LabelHeight: 150, Padding: 6,
+	},
+}
+
+// DefaultLayout - раскладка по умолчанию
+const DefaultLayout = "a4-3x8"
+
+// GetLayout возвращает раскладку по имени
+func GetLayout(name string) (Layout, error) {
+	if name == "" {
+		name = DefaultLayout
+	}
+	l, ok := layouts[name]
+	if !ok {
+		return Layout{}, fmt.Errorf("%w: %s", ErrUnknownLayout, name)
+	}
+	return l, nil
+}
+
+// Layouts - список раскладок (по имени)
+func Layouts() []Layout {
+	out := make([]Layout, 0, len(layouts))
+	for _, l := range layouts {
+		out = append(out, l)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Label - одна этикетка
+type Label struct {
+	QRContent string   // содержимое QR (qr.Encode)
+	Title     string   // крупная строка: название товара или код ячейки
+	Lines     []string // мелкие строки: SKU, P/N, локация...
+}
+
+var (
+	fontOnce  sync.Once
+	fontBytes []byte
+	fontErr   error
+)
+
+// fontCandidates - где искать шрифт, если LABEL_FONT не задан
+var fontCandidates = []string{
+	"static/fonts/DejaVuSans.ttf",
+	"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf", // Debian/Ubuntu
+	"/usr/share/fonts/dejavu/DejaVuSans.ttf",          // Alpine
+	"/usr/share/fonts/TTF/DejaVuSans.ttf",
+}
+
+func loadFont() ([]byte, error) {
+	fontOnce.Do(func() {
+		paths := fontCandidates
+		if p := os.Getenv("LABEL_FONT"); p != "" {
+			paths = []string{p}
+		}
+		for _, p := range paths {
+			if b, err := os.ReadFile(p); err == nil {
+				fontBytes = b
+				return
+			}
+		}
+		fontErr = ErrFontNotFound
+	})
+	return fontBytes, fontErr
+}
+
+const fontFamily = "labelfont"
+
+// Render пишет PDF с этикетками. start - номер первой свободной позиции
+// на первом листе (1 - с начала), чтобы допечатывать начатый лист.
+func Render(w io.Writer, layout Layout, items []Label, start int) error {
+	font, err := loadFont()
+	if err != nil {
+		return err
+	}
+
+	pdf := fpdf.NewCustom(&fpdf.InitType{
+		UnitStr: "mm",
+		Size:    fpdf.SizeType{Wd: layout.PageWidth, Ht: layout.PageHeight},
+	})
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetCellMargin(0)
+	pdf.AddUTF8FontFromBytes(fontFamily, "", font)
+
+	pos := 0
+	if start > 1 && start <= layout.PerPage() {
+		pos = start - 1
+	}
+	if len(items) == 0 {
+		pdf.AddPage()
+	}
+	for i, label := range items {
+		if i == 0 || pos%layout.PerPage() == 0 {
+			pdf.AddPage()
+		}
+		cell := pos % layout.PerPage()
+		x := layout.MarginLeft + float64(cell%layout.Columns)*(layout.LabelWidth+layout.GapX)
+		y := layout.MarginTop + float64(cell/layout.Columns)*(layout.LabelHeight+layout.GapY)
+		if err := drawLabel(pdf, layout, x, y, label, i); err != nil {
+			return err
+		}
+		pos++
+	}
+
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
+
+// drawLabel рисует одну этикетку с левым верхним углом (x, y)
+func drawLabel(pdf *fpdf.Fpdf, l Layout, x, y float64, label Label, n int) error {
+	pad := l.Padding
+	innerW, innerH := l.LabelWidth-2*pad, l.LabelHeight-2*pad
+
+	// QR: квадрат по меньшей стороне; в вертикальной раскладке - по ширине
+	qrSize := innerH
+	if l.vertical() {
+		qrSize = innerW
+	}
+	if qrSize > innerW {
+		qrSize = innerW
+	}
+
+	png, err := qr.Render(label.QRContent, qr.RenderOptions{
+		Size:       600,
+		Level:      "Q",
+		Margin:     0,
+		Foreground: "000000",
+		Background: "FFFFFF",
+		Format:     qr.FormatPNG,
+	})
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("qr%d", n)
+	opts := fpdf.ImageOptions{ImageType: "PNG"}
+	pdf.RegisterImageOptionsReader(name, opts, bytes.NewReader(png))
+	pdf.ImageOptions(name, x+pad, y+pad, qrSize, qrSize, false, opts, 0, "")
+
+	// Текст: справа от QR или под ним
+	tx, ty, tw := x+pad+qrSize+pad, y+pad, innerW-qrSize-pad
+	if l.vertical() {
+		tx, ty, tw = x+pad, y+pad+qrSize+pad, innerW
+	}
+	if tw < 5 {
+		return nil
+	}
+
+	titleSize, lineSize := fontSizes(l)
+	pdf.SetFont(fontFamily, "", titleSize)
+	lineH := titleSize * 0.45
+	for _, line := range wrap(pdf, label.Title, tw, 2) {
+		pdf.SetXY(tx, ty)
+		pdf.CellFormat(tw, lineH, line, "", 0, "L", false, 0, "")
+		ty += lineH
+	}
+	ty += lineH * 0.3
+
+	pdf.SetFont(fontFamily, "", lineSize)
+	lineH = lineSize * 0.45
+	bottom := y + l.LabelHeight - pad
+	for _, line := range label.Lines {
+		if line == "" {
+			continue
+		}
+		if ty+lineH > bottom {
+			break
+		}
+		pdf.SetXY(tx, ty)
+		pdf.CellFormat(tw, lineH, fit(pdf, line, tw), "", 0, "L", false, 0, "")
+		ty += lineH
+	}
+	return nil
+}
+
+// fontSizes - размеры шрифтов (pt) в зависимости от высоты этикетки
+func fontSizes(l Layout) (title, line float64) {
+	switch {
+	case l.LabelHeight >= 100:
+		return 20, 14
+	case l.LabelHeight >= 38:
+		return 10, 8
+	default:
+		return 9, 7
+	}
+}
+
+// wrap разбивает текст по словам не более чем на maxLines строк шириной w
+func wrap(pdf *fpdf.Fpdf, text string, w float64, maxLines int) []string {
+	var lines []string
+	current := ""
+	for _, word := range strings.Fields(text) {
+		candidate := strings.TrimSpace(current + " " + word)
+		if current != "" && pdf.GetStringWidth(candidate) > w {
+			lines = append(lines, current)
+			current = word
+			continue
+		}
+		current = candidate
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		lines[maxLines-1] += "…"
+	}
+	for i := range lines {
+		lines[i] = fit(pdf, lines[i], w)
+	}
+	return lines
+}
+
+// fit обрезает строку с многоточием, чтобы она поместилась в ширину w
+func fit(pdf *fpdf.Fpdf, s string, w float64) string {
+	if pdf.GetStringWidth(s) <= w {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"…") > w {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
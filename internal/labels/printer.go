@@ -0,0 +1,76 @@
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultPrinterPort - порт raw печати (JetDirect)
+const DefaultPrinterPort = "9100"
+
+// printTimeout - таймаут подключения и отправки на принтер
+const printTimeout = 10 * time.Second
+
+// ErrUnknownPrinter - принтер не описан в LABEL_PRINTERS
+var ErrUnknownPrinter = errors.New("labels: unknown printer")
+
+// Printers - принтеры из LABEL_PRINTERS ("склад=10.0.0.5:9100,приёмка=10.0.0.6").
+// HTTP API печатает только на них, чтобы сервер нельзя было заставить
+// подключиться к произвольному адресу.
+func Printers() map[string]string {
+	out := make(map[string]string)
+	for _, entry := range strings.Split(os.Getenv("LABEL_PRINTERS"), ",") {
+		name, addr, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" || addr == "" {
+			continue
+		}
+		out[strings.TrimSpace(name)] = withDefaultPort(strings.TrimSpace(addr))
+	}
+	return out
+}
+
+// PrinterNames - имена настроенных принтеров
+func PrinterNames() []string {
+	var names []string
+	for name := range Printers() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PrinterAddr возвращает адрес принтера по имени
+func PrinterAddr(name string) (string, error) {
+	addr, ok := Printers()[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownPrinter, name)
+	}
+	return addr, nil
+}
+
+// SendRaw отправляет данные (ZPL) на принтер по raw TCP (порт 9100 по умолчанию)
+func SendRaw(addr string, data []byte) error {
+	conn, err := net.DialTimeout("tcp", withDefaultPort(addr), printTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetWriteDeadline(time.Now().Add(printTimeout)); err != nil {
+		return err
+	}
+	_, err = conn.Write(data)
+	return err
+}
+
+func withDefaultPort(addr string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(addr, DefaultPrinterPort)
+}
@@ -0,0 +1,73 @@
+package labels
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestSendRaw(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	zpl := []byte("^XA^FO50,50^FDtest^FS^XZ")
+	if err := SendRaw(ln.Addr().String(), zpl); err != nil {
+		t.Fatalf("SendRaw: %v", err)
+	}
+	if got := <-received; !bytes.Equal(got, zpl) {
+		t.Fatalf("printer received %q, want %q", got, zpl)
+	}
+}
+
+func TestSendRawUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	if err := SendRaw(addr, []byte("^XA^XZ")); err == nil {
+		t.Fatal("expected error for closed port")
+	}
+}
+
+func TestWithDefaultPort(t *testing.T) {
+	cases := map[string]string{
+		"10.0.0.5":      "10.0.0.5:9100",
+		"10.0.0.5:6101": "10.0.0.5:6101",
+		"printer.local": "printer.local:9100",
+	}
+	for in, want := range cases {
+		if got := withDefaultPort(in); got != want {
+			t.Errorf("withDefaultPort(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPrinters(t *testing.T) {
+	t.Setenv("LABEL_PRINTERS", " склад = 10.0.0.5:9100, приёмка=10.0.0.6,broken,=1.2.3.4")
+	got := Printers()
+	if len(got) != 2 || got["склад"] != "10.0.0.5:9100" || got["приёмка"] != "10.0.0.6:9100" {
+		t.Fatalf("Printers() = %v", got)
+	}
+	if _, err := PrinterAddr("офис"); err == nil {
+		t.Fatal("expected ErrUnknownPrinter")
+	}
+}
@@ -0,0 +1,204 @@
+package labels
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"QR-GENERATOR/internal/qr"
+)
+
+// Режимы печати QR в ZPL
+const (
+	// QRNative - встроенный генератор принтера (^BQ), самый компактный вывод
+	QRNative = "native"
+	// QRGraphic - готовое изображение (^GFA), модули совпадают с PNG/PDF
+	QRGraphic = "graphic"
+)
+
+// DefaultZPLLayout - раскладка по умолчанию для термопринтеров
+const DefaultZPLLayout = "thermal-58x40"
+
+// ZPLOptions - параметры ZPL вывода
+type ZPLOptions struct {
+	DPI    int    // 203, 300 или 600
+	QRMode string // native или graphic
+	// Font - шрифт принтера для текста. Пусто - встроенный ^A0 (без кириллицы
+	// на части моделей), иначе TTF на принтере, например "E:TT0003M_.TTF".
+	Font string
+}
+
+// DefaultZPLOptions - параметры из окружения: ZPL_DPI, ZPL_QR_MODE, ZPL_FONT
+func DefaultZPLOptions() ZPLOptions {
+	o := ZPLOptions{DPI: 203, QRMode: QRNative, Font: os.Getenv("ZPL_FONT")}
+	if v, err := strconv.Atoi(os.Getenv("ZPL_DPI")); err == nil && validDPI(v) {
+		o.DPI = v
+	}
+	if v := os.Getenv("ZPL_QR_MODE"); v == QRGraphic {
+		o.QRMode = v
+	}
+	return o
+}
+
+func validDPI(dpi int) bool {
+	return dpi == 203 || dpi == 300 || dpi == 600
+}
+
+// Validate проверяет параметры
+func (o ZPLOptions) Validate() error {
+	if !validDPI(o.DPI) {
+		return fmt.Errorf("labels: dpi должен быть 203, 300 или 600")
+	}
+	if o.QRMode != QRNative && o.QRMode != QRGraphic {
+		return fmt.Errorf("labels: qr mode должен быть native или graphic")
+	}
+	return nil
+}
+
+// dots переводит мм в точки принтера
+func (o ZPLOptions) dots(mm float64) int {
+	return int(math.Round(mm * float64(o.DPI) / 25.4))
+}
+
+// RenderZPL пишет по одной ZPL этикетке (^XA…^XZ) на каждый элемент.
+// Используются размеры этикетки раскладки; колонки/ряды листа не учитываются.
+func RenderZPL(w io.Writer, layout Layout, items []Label, o ZPLOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	for _, label := range items {
+		zpl, err := zplLabel(layout, label, o)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, zpl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func zplLabel(l Layout, label Label, o ZPLOptions) (string, error) {
+	width, height := o.dots(l.LabelWidth), o.dots(l.LabelHeight)
+	pad := o.dots(l.Padding)
+	innerW, innerH := width-2*pad, height-2*pad
+
+	qrSize := innerH
+	if l.vertical() {
+		qrSize = innerW
+	}
+	if qrSize > innerW {
+		qrSize = innerW
+	}
+
+	modules, err := qr.Modules(label.QRContent, "Q")
+	if err != nil {
+		return "", err
+	}
+	n := len(modules)
+	mag := qrSize / n
+	if mag < 1 {
+		return "", fmt.Errorf("labels: этикетка слишком мала для QR (%d модулей)", n)
+	}
+
+	var b strings.Builder
+	b.WriteString("^XA\n^CI28\n")
+	fmt.Fprintf(&b, "^PW%d\n^LL%d\n^LH0,0\n", width, height)
+
+	switch o.QRMode {
+	case QRNative:
+		if mag > 10 {
+			mag = 10 // максимум ^BQ
+		}
+		// ^BQ добавляет сверху 10 точек служебного отступа - компенсируем
+		y := pad - 10
+		if y < 0 {
+			y = 0
+		}
+		fmt.Fprintf(&b, "^FO%d,%d^BQN,2,%d^FH^FDQA,%s^FS\n", pad, y, mag, zplEscape(label.QRContent))
+	case QRGraphic:
+		fmt.Fprintf(&b, "^FO%d,%d%s^FS\n", pad, pad, zplGraphic(modules, mag))
+	}
+	qrSize = n * mag
+
+	tx, ty, tw := pad+qrSize+pad, pad, innerW-qrSize-pad
+	if l.vertical() {
+		tx, ty, tw = pad, pad+qrSize+pad, innerW
+	}
+	if tw < o.dots(5) {
+		b.WriteString("^XZ\n")
+		return b.String(), nil
+	}
+
+	titlePt, linePt := fontSizes(l)
+	titleH, lineH := ptToDots(titlePt, o.DPI), ptToDots(linePt, o.DPI)
+	bottom := height - pad
+
+	if label.Title != "" {
+		fmt.Fprintf(&b, "^FO%d,%d%s^FB%d,2,0,L,0^FH^FD%s^FS\n", tx, ty, zplFont(o, titleH), tw, zplEscape(label.Title))
+		ty += titleH*2 + titleH/3
+	}
+	for _, line := range label.Lines {
+		if line == "" {
+			continue
+		}
+		if ty+lineH > bottom {
+			break
+		}
+		fmt.Fprintf(&b, "^FO%d,%d%s^FB%d,1,0,L,0^FH^FD%s^FS\n", tx, ty, zplFont(o, lineH), tw, zplEscape(line))
+		ty += lineH + lineH/4
+	}
+
+	b.WriteString("^XZ\n")
+	return b.String(), nil
+}
+
+// ptToDots - высота шрифта в точках принтера
+func ptToDots(pt float64, dpi int) int {
+	return int(math.Round(pt / 72 * float64(dpi)))
+}
+
+func zplFont(o ZPLOptions, h int) string {
+	if o.Font != "" {
+		return fmt.Sprintf("^A@N,%d,%d,%s", h, h, o.Font)
+	}
+	return fmt.Sprintf("^A0N,%d,%d", h, h)
+}
+
+// zplEscape экранирует управляющие символы для ^FH (индикатор "_")
+func zplEscape(s string) string {
+	r := strings.NewReplacer("_", "_5F", "^", "_5E", "~", "_7E", "\n", " ", "\r", "")
+	return r.Replace(s)
+}
+
+// zplGraphic - QR как монохромное изображение ^GFA (hex, 1 бит на точку)
+func zplGraphic(modules [][]bool, mag int) string {
+	size := len(modules) * mag
+	rowBytes := (size + 7) / 8
+	total := rowBytes * size
+
+	var hex strings.Builder
+	row := make([]byte, rowBytes)
+	for _, line := range modules {
+		for i := range row {
+			row[i] = 0
+		}
+		for x, dark := range line {
+			if !dark {
+				continue
+			}
+			for dx := 0; dx < mag; dx++ {
+				px := x*mag + dx
+				row[px/8] |= 0x80 >> (px % 8)
+			}
+		}
+		encoded := fmt.Sprintf("%X", row)
+		for dy := 0; dy < mag; dy++ {
+			hex.WriteString(encoded)
+		}
+	}
+	return fmt.Sprintf("^GFA,%d,%d,%d,%s", total, total, rowBytes, hex.String())
+}
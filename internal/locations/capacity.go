@@ -0,0 +1,243 @@
+package locations
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"QR-GENERATOR/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrOverCapacity - размещение нарушает ограничения локации
+var ErrOverCapacity = errors.New("location capacity exceeded")
+
+// Usage - занятость локации вместе с вложенными
+type Usage struct {
+	Units  int     `json:"units"`
+	Weight float64 `json:"weight"` // кг
+	Volume float64 `json:"volume"` // л
+}
+
+func (u Usage) add(o Usage) Usage {
+	return Usage{u.Units + o.Units, u.Weight + o.Weight, u.Volume + o.Volume}
+}
+
+// Need - сколько займут qty единиц товара
+func Need(item models.Item, qty int) Usage {
+	return Usage{qty, item.Weight * float64(qty), item.Volume * float64(qty)}
+}
+
+// Violation - нарушенное ограничение локации
+type Violation struct {
+	LocationID string `json:"location_id"`
+	Code       string `json:"code"`
+	Rule       string `json:"rule"` // units, weight, volume, category
+	Message    string `json:"message"`
+}
+
+// RejectOverCapacity - политика из CAPACITY_POLICY: reject (по умолчанию)
+// отклоняет перемещение сверх ограничений, warn выполняет его с предупреждением
+func RejectOverCapacity() bool {
+	return os.Getenv("CAPACITY_POLICY") != "warn"
+}
+
+// Categories - разрешённые категории локации (пусто - любые)
+func Categories(loc models.Location) []string {
+	return splitCategories(loc.AllowedCategories)
+}
+
+// NormalizeCategories убирает пробелы и пустые элементы: " Фильтры, ,Масла" -> "Фильтры,Масла"
+func NormalizeCategories(s string) string {
+	return strings.Join(splitCategories(s), ",")
+}
+
+func splitCategories(s string) []string {
+	var out []string
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// AllowsCategory - можно ли хранить категорию в локации; restricted - у
+// локации есть список категорий (отведённая зона)
+func AllowsCategory(loc models.Location, category string) (allowed, restricted bool) {
+	cats := Categories(loc)
+	if len(cats) == 0 {
+		return true, false
+	}
+	for _, c := range cats {
+		if strings.EqualFold(c, category) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// FreeRatio - доля свободного места после размещения need: минимум по
+// заданным ограничениям (шт, кг, л), 1 - ограничений нет; < 0 - не помещается
+func FreeRatio(loc models.Location, used, need Usage) float64 {
+	ratio := 1.0
+	check := func(limit, used, need float64) {
+		if limit > 0 {
+			if r := (limit - used - need) / limit; r < ratio {
+				ratio = r
+			}
+		}
+	}
+	check(float64(loc.MaxUnits), float64(used.Units), float64(need.Units))
+	check(loc.MaxWeight, used.Weight, need.Weight)
+	check(loc.MaxVolume, used.Volume, need.Volume)
+	return ratio
+}
+
+// Check проверяет размещение need товара категории category в chain - ячейке
+// и её предках (ограничение полки действует на все её ячейки). usage -
+// занятость каждой локации цепочки. Товар, переносимый из fromPath, уже
+// учтён в общих с ним предках, для них need не добавляется.
+func Check(chain []models.Location, usage map[string]Usage, category string, need Usage, fromPath string) []Violation {
+	var out []Violation
+	for _, loc := range chain {
+		if allowed, _ := AllowsCategory(loc, category); !allowed {
+			out = append(out, Violation{loc.ID, loc.Code, "category",
+				fmt.Sprintf("%s: разрешены категории %s, а у товара %q", loc.Code, loc.AllowedCategories, category)})
+		}
+
+		add := need
+		if fromPath != "" && strings.HasPrefix(fromPath, loc.Path) {
+			add = Usage{}
+		}
+		used := usage[loc.ID]
+		if loc.MaxUnits > 0 && used.Units+add.Units > loc.MaxUnits {
+			out = append(out, Violation{loc.ID, loc.Code, "units",
+				fmt.Sprintf("%s: вместимость %d шт, занято %d, размещается %d", loc.Code, loc.MaxUnits, used.Units, add.Units)})
+		}
+		if loc.MaxWeight > 0 && used.Weight+add.Weight > loc.MaxWeight {
+			out = append(out, Violation{loc.ID, loc.Code, "weight",
+				fmt.Sprintf("%s: нагрузка до %g кг, занято %g, размещается %g", loc.Code, loc.MaxWeight, used.Weight, add.Weight)})
+		}
+		if loc.MaxVolume > 0 && used.Volume+add.Volume > loc.MaxVolume {
+			out = append(out, Violation{loc.ID, loc.Code, "volume",
+				fmt.Sprintf("%s: объём до %g л, занято %g, размещается %g", loc.Code, loc.MaxVolume, used.Volume, add.Volume)})
+		}
+	}
+	return out
+}
+
+// CheckPlacement проверяет перемещение qty единиц товара в ячейку target
+// из fromLocationID (пусто - неразмещённый остаток). Вызывается в транзакции:
+// ячейка и все её предки блокируются до её конца, чтобы параллельные
+// размещения в разные ячейки одной полки или зоны проверялись по очереди.
+func CheckPlacement(tx *gorm.DB, target models.Location, fromLocationID string, item models.Item, qty int) ([]Violation, error) {
+	chain, err := lockChain(tx, target)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(chain))
+	for i, l := range chain {
+		ids[i] = l.ID
+	}
+	usage, err := UsageUnder(tx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var fromPath string
+	if fromLocationID != "" {
+		var from models.Location
+		if err := tx.Select("id", "path").First(&from, "id = ?", fromLocationID).Error; err == nil {
+			fromPath = from.Path
+		}
+	}
+	return Check(chain, usage, item.Category, Need(item, qty), fromPath), nil
+}
+
+// lockChain блокирует FOR UPDATE ячейку и её предков и возвращает их
+// свежие записи от склада к ячейке. Строки блокируются в порядке path -
+// одинаковом для всех транзакций, поэтому взаимных блокировок нет.
+func lockChain(tx *gorm.DB, target models.Location) ([]models.Location, error) {
+	path := target.Path
+	if path == "" {
+		if err := tx.Select("path").First(&target, "id = ?", target.ID).Error; err != nil {
+			return nil, err
+		}
+		path = target.Path
+	}
+	ids := strings.Split(strings.Trim(path, "/"), "/")
+
+	var chain []models.Location
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).Order("path").Find(&chain).Error; err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 || chain[len(chain)-1].ID != target.ID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return chain, nil
+}
+
+// usageRow - строка агрегата остатков
+type usageRow struct {
+	LocationID string
+	Units      int
+	Weight     float64
+	Volume     float64
+}
+
+// usageColumns - агрегат остатков sb с товарами i
+const usageColumns = `SUM(sb.quantity) AS units,
+	COALESCE(SUM(sb.quantity * i.weight), 0) AS weight,
+	COALESCE(SUM(sb.quantity * i.volume), 0) AS volume`
+
+// UsageUnder - занятость локаций ids вместе с вложенными
+func UsageUnder(db *gorm.DB, ids []string) (map[string]Usage, error) {
+	var rows []usageRow
+	err := db.Raw(`SELECT root.id AS location_id, `+usageColumns+`
+		FROM locations root
+		JOIN locations l ON LEFT(l.path, LENGTH(root.path)) = root.path
+		JOIN stock_balances sb ON sb.location_id = l.id
+		JOIN items i ON i.id = sb.item_id AND i.deleted_at IS NULL
+		WHERE root.id IN ? AND sb.quantity > 0
+		GROUP BY root.id`, ids).Scan(&rows).Error
+	return usageMap(rows), err
+}
+
+// BinUsage - занятость всех ячеек с остатками (для подбора места)
+func BinUsage(db *gorm.DB) (map[string]Usage, error) {
+	var rows []usageRow
+	err := db.Raw(`SELECT sb.location_id, ` + usageColumns + `
+		FROM stock_balances sb
+		JOIN items i ON i.id = sb.item_id AND i.deleted_at IS NULL
+		WHERE sb.location_id <> '' AND sb.quantity > 0
+		GROUP BY sb.location_id`).Scan(&rows).Error
+	return usageMap(rows), err
+}
+
+// RollUp добавляет к занятости ячеек занятость их предков по путям list
+func RollUp(list []models.Location, bins map[string]Usage) map[string]Usage {
+	out := make(map[string]Usage, len(list))
+	for _, l := range list {
+		u, ok := bins[l.ID]
+		if !ok {
+			continue
+		}
+		for _, id := range strings.Split(strings.Trim(l.Path, "/"), "/") {
+			out[id] = out[id].add(u)
+		}
+	}
+	return out
+}
+
+func usageMap(rows []usageRow) map[string]Usage {
+	out := make(map[string]Usage, len(rows))
+	for _, r := range rows {
+		out[r.LocationID] = Usage{r.Units, r.Weight, r.Volume}
+	}
+	return out
+}
@@ -0,0 +1,119 @@
+package locations
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"QR-GENERATOR/internal/models"
+)
+
+// Веса критериев подбора места
+const (
+	scoreSameBin   = 100 // товар уже лежит в ячейке - докладываем туда же
+	scoreProximity = 20  // за каждый общий уровень с ячейкой, где есть товар
+	scoreCategory  = 30  // ячейка в зоне, отведённой под категорию товара
+	scoreFree      = 20  // умножается на долю свободного места после размещения
+)
+
+// Suggestion - ячейка-кандидат для размещения
+type Suggestion struct {
+	Location  models.Location `json:"location"`
+	Score     float64         `json:"score"`
+	FreeRatio float64         `json:"free_ratio"` // доля свободного места после размещения (1 - без ограничений)
+	Reasons   []string        `json:"reasons"`
+}
+
+// Suggest ранжирует ячейки из list для qty единиц товара. usage - занятость
+// всех локаций (RollUp), stock - ячейки, где товар уже лежит. Ячейки, где
+// размещение нарушит ограничения её или предков, не предлагаются.
+func Suggest(list []models.Location, usage map[string]Usage, item models.Item, qty int, stock []string) []Suggestion {
+	byID := make(map[string]models.Location, len(list))
+	for _, l := range list {
+		byID[l.ID] = l
+	}
+	stockPaths := make(map[string]string, len(stock))
+	for _, id := range stock {
+		if l, ok := byID[id]; ok {
+			stockPaths[id] = l.Path
+		}
+	}
+	need := Need(item, qty)
+
+	out := []Suggestion{}
+	for _, bin := range list {
+		if bin.Kind != KindBin {
+			continue
+		}
+		chain := chainOf(bin, byID)
+		if len(Check(chain, usage, item.Category, need, "")) > 0 {
+			continue
+		}
+
+		s := Suggestion{Location: bin, FreeRatio: 1, Reasons: []string{}}
+		for _, l := range chain {
+			if r := FreeRatio(l, usage[l.ID], need); r < s.FreeRatio {
+				s.FreeRatio = r
+			}
+		}
+		s.Score = scoreFree * s.FreeRatio
+		if s.FreeRatio < 1 {
+			s.Reasons = append(s.Reasons, fmt.Sprintf("свободно %d%% после размещения", int(math.Round(s.FreeRatio*100))))
+		}
+
+		if _, ok := stockPaths[bin.ID]; ok {
+			s.Score += scoreSameBin
+			s.Reasons = append(s.Reasons, "товар уже лежит в этой ячейке")
+		} else if depth, near := nearestStock(bin.Path, stockPaths); depth > 0 {
+			s.Score += scoreProximity * float64(depth)
+			s.Reasons = append(s.Reasons, "остаток товара тоже в "+byID[near].Code)
+		}
+
+		for _, l := range chain {
+			if allowed, restricted := AllowsCategory(l, item.Category); allowed && restricted {
+				s.Score += scoreCategory
+				s.Reasons = append(s.Reasons, l.Code+" отведена под категорию "+item.Category)
+				break
+			}
+		}
+		out = append(out, s)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Location.Code < out[j].Location.Code
+	})
+	return out
+}
+
+// chainOf - предки ячейки и она сама, от склада вниз
+func chainOf(bin models.Location, byID map[string]models.Location) []models.Location {
+	var chain []models.Location
+	for _, id := range strings.Split(strings.Trim(bin.Path, "/"), "/") {
+		if l, ok := byID[id]; ok {
+			chain = append(chain, l)
+		}
+	}
+	return chain
+}
+
+// nearestStock - число общих уровней пути с ближайшей ячейкой из stockPaths
+// и ID их общего предка
+func nearestStock(path string, stockPaths map[string]string) (int, string) {
+	own := strings.Split(strings.Trim(path, "/"), "/")
+	best, near := 0, ""
+	for _, p := range stockPaths {
+		other := strings.Split(strings.Trim(p, "/"), "/")
+		n := 0
+		for n < len(own) && n < len(other) && own[n] == other[n] {
+			n++
+		}
+		if n > best {
+			best, near = n, own[n-1]
+		}
+	}
+	return best, near
+}
@@ -0,0 +1,276 @@
+// Package locations - иерархия мест хранения:
+// склад → зона → ряд → секция → полка → ячейка.
+//
+// Каждая локация хранит родителя (parent_id) и материализованный путь
+// "/<id склада>/<id зоны>/.../<id>/", поэтому поддерево ("всё в зоне B")
+// выбирается одним условием path LIKE. Уровни можно пропускать (склад → ячейка),
+// но вложенная локация всегда ниже родителя. Товары хранятся только в ячейках.
+package locations
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"QR-GENERATOR/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Уровни иерархии сверху вниз
+const (
+	KindWarehouse = "warehouse"
+	KindZone      = "zone"
+	KindRow       = "row"
+	KindSection   = "section"
+	KindShelf     = "shelf"
+	KindBin       = "bin"
+)
+
+// Kinds - уровни по порядку вложенности
+var Kinds = []string{KindWarehouse, KindZone, KindRow, KindSection, KindShelf, KindBin}
+
+var (
+	// ErrUnknownKind - неизвестный уровень
+	ErrUnknownKind = errors.New("kind должен быть warehouse, zone, row, section, shelf или bin")
+	// ErrBadParent - родитель должен быть уровнем выше
+	ErrBadParent = errors.New("локация должна быть вложена в локацию более высокого уровня")
+	// ErrCycle - локацию нельзя переместить внутрь неё самой
+	ErrCycle = errors.New("локацию нельзя переместить внутрь неё самой")
+	// ErrNotBin - товар можно хранить только в ячейке
+	ErrNotBin = errors.New("товар можно хранить только в ячейке (bin)")
+)
+
+// Level - глубина уровня (0 - склад) или -1 для неизвестного
+func Level(kind string) int {
+	for i, k := range Kinds {
+		if k == kind {
+			return i
+		}
+	}
+	return -1
+}
+
+// CheckParent проверяет, что kind можно вложить в parent (nil - корень).
+// Склад всегда корень; прочие уровни без родителя допускаются для локаций,
+// созданных до появления иерархии.
+func CheckParent(kind string, parent *models.Location) error {
+	if Level(kind) < 0 {
+		return ErrUnknownKind
+	}
+	if parent == nil {
+		return nil
+	}
+	if kind == KindWarehouse || Level(parent.Kind) >= Level(kind) {
+		return fmt.Errorf("%w: %s в %s", ErrBadParent, kind, parent.Kind)
+	}
+	return nil
+}
+
+// CheckKind проверяет смену уровня локации на kind: она остаётся ниже
+// родителя и выше всех вложенных локаций
+func CheckKind(db *gorm.DB, loc models.Location, kind string) error {
+	var parent *models.Location
+	if loc.ParentID != "" {
+		parent = &models.Location{}
+		if err := db.First(parent, "id = ?", loc.ParentID).Error; err != nil {
+			return err
+		}
+	}
+	if err := CheckParent(kind, parent); err != nil {
+		return err
+	}
+
+	var kinds []string
+	if err := db.Model(&models.Location{}).Where("parent_id = ?", loc.ID).Distinct().Pluck("kind", &kinds).Error; err != nil {
+		return err
+	}
+	for _, k := range kinds {
+		if Level(k) <= Level(kind) {
+			return fmt.Errorf("%w: внутри есть %s", ErrBadParent, k)
+		}
+	}
+	return nil
+}
+
+// PathOf - путь локации id с родителем parent (nil - корень)
+func PathOf(parent *models.Location, id string) string {
+	if parent == nil {
+		return "/" + id + "/"
+	}
+	return parent.Path + id + "/"
+}
+
+// Under - условие "локация и всё, что в ней" для запроса по locations
+func Under(db *gorm.DB, loc models.Location) *gorm.DB {
+	return db.Where("locations.path LIKE ?", escapeLike(loc.Path)+"%")
+}
+
+// SubtreeIDs - ID локации и всех вложенных
+func SubtreeIDs(db *gorm.DB, loc models.Location) ([]string, error) {
+	var ids []string
+	err := Under(db.Model(&models.Location{}), loc).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// Ancestors - предки локации от склада к родителю
+func Ancestors(db *gorm.DB, loc models.Location) ([]models.Location, error) {
+	ids := strings.Split(strings.Trim(loc.Path, "/"), "/")
+	if len(ids) <= 1 {
+		return nil, nil
+	}
+	ids = ids[:len(ids)-1]
+	var list []models.Location
+	if err := db.Where("id IN ?", ids).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return len(list[i].Path) < len(list[j].Path) })
+	return list, nil
+}
+
+// RequireBin проверяет, что в локации можно хранить товар, и возвращает её.
+// Пустой ID - товар принят, но не размещён, это допустимо.
+func RequireBin(db *gorm.DB, id string) (models.Location, error) {
+	var loc models.Location
+	if id == "" {
+		return loc, nil
+	}
+	if err := db.Select("id", "code", "kind").First(&loc, "id = ?", id).Error; err != nil {
+		return loc, err
+	}
+	if loc.Kind != KindBin {
+		return loc, ErrNotBin
+	}
+	return loc, nil
+}
+
+// Move переносит локацию со всем содержимым в parent (nil - в корень):
+// стеллаж в другую секцию, ячейку на другую полку. Остатки остаются
+// в своих ячейках, меняются пути и ряд/секция/полка поддерева.
+func Move(tx *gorm.DB, loc *models.Location, parent *models.Location) error {
+	if err := CheckParent(loc.Kind, parent); err != nil {
+		return err
+	}
+	if parent != nil && strings.HasPrefix(parent.Path, loc.Path) {
+		return ErrCycle
+	}
+
+	oldPath, newPath := loc.Path, PathOf(parent, loc.ID)
+	parentID := ""
+	if parent != nil {
+		parentID = parent.ID
+	}
+	if err := tx.Model(&models.Location{}).Where("id = ?", loc.ID).Update("parent_id", parentID).Error; err != nil {
+		return err
+	}
+	// Вместе с выведенными из работы, чтобы при восстановлении путь был верным
+	err := Under(tx.Unscoped().Model(&models.Location{}), *loc).
+		UpdateColumn("path", gorm.Expr("? || SUBSTRING(path FROM ?)", newPath, utf8.RuneCountInString(oldPath)+1)).Error
+	if err != nil {
+		return err
+	}
+	loc.ParentID, loc.Path = parentID, newPath
+	return SyncPlace(tx, *loc)
+}
+
+// ApplyPlace заполняет ряд/секцию/полку локации кодами предков этих уровней
+// (их печатают этикетки и фильтрует --genqr). Поля без такого предка не меняются.
+func ApplyPlace(loc *models.Location, ancestors []models.Location) {
+	for _, a := range ancestors {
+		switch a.Kind {
+		case KindRow:
+			loc.Row = a.Code
+		case KindSection:
+			loc.Section = a.Code
+		case KindShelf:
+			loc.Shelf = a.Code
+		}
+	}
+}
+
+// SyncPlace пересчитывает ряд/секцию/полку во всём поддереве loc
+// (после переноса в другой контейнер)
+func SyncPlace(tx *gorm.DB, loc models.Location) error {
+	var subtree []models.Location
+	if err := Under(tx, loc).Find(&subtree).Error; err != nil {
+		return err
+	}
+	ancestors, err := Ancestors(tx, loc)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]models.Location, len(subtree)+len(ancestors))
+	for _, l := range append(ancestors, subtree...) {
+		byID[l.ID] = l
+	}
+
+	for _, l := range subtree {
+		var chain []models.Location
+		ids := strings.Split(strings.Trim(l.Path, "/"), "/")
+		for _, id := range ids[:len(ids)-1] {
+			chain = append(chain, byID[id])
+		}
+		row, section, shelf := l.Row, l.Section, l.Shelf
+		// Коды берутся только из текущих предков: если предок сменил уровень
+		// (ряд -> секция), старый код ряда не должен остаться. Локации вне
+		// иерархии (без родителя) сохраняют заданные вручную значения.
+		if len(chain) > 0 {
+			l.Row, l.Section, l.Shelf = "", "", ""
+		}
+		ApplyPlace(&l, chain)
+		if l.Row == row && l.Section == section && l.Shelf == shelf {
+			continue
+		}
+		err := tx.Model(&models.Location{}).Where("id = ?", l.ID).
+			Updates(map[string]interface{}{"row": l.Row, "section": l.Section, "shelf": l.Shelf}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tree собирает плоский список в деревья. Корни - локации, чей родитель
+// не вошёл в список (при выборке поддерева - его вершина).
+func Tree(list []models.Location) []models.Location {
+	byParent := make(map[string][]models.Location)
+	present := make(map[string]bool, len(list))
+	for _, l := range list {
+		present[l.ID] = true
+	}
+	var roots []models.Location
+	for _, l := range list {
+		if l.ParentID != "" && present[l.ParentID] {
+			byParent[l.ParentID] = append(byParent[l.ParentID], l)
+		} else {
+			roots = append(roots, l)
+		}
+	}
+
+	var attach func(l *models.Location)
+	attach = func(l *models.Location) {
+		l.Children = byParent[l.ID]
+		for i := range l.Children {
+			attach(&l.Children[i])
+		}
+	}
+	for i := range roots {
+		attach(&roots[i])
+	}
+	return roots
+}
+
+// Backfill переводит локации, созданные до иерархии, в ячейки верхнего уровня
+func Backfill(db *gorm.DB) error {
+	if err := db.Exec(`UPDATE locations SET kind = ? WHERE kind IS NULL OR kind = ''`, KindBin).Error; err != nil {
+		return err
+	}
+	return db.Exec(`UPDATE locations SET path = '/' || id || '/' WHERE path IS NULL OR path = ''`).Error
+}
+
+// escapeLike экранирует спецсимволы LIKE в ID
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
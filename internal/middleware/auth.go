@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"QR-GENERATOR/internal/auth"
+	"QR-GENERATOR/internal/database"
+	"QR-GENERATOR/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Ключи gin-контекста, которые заполняет AuthRequired
+const (
+	ContextUserID    = "userID"
+	ContextUsername  = "username"
+	ContextRole      = "role"
+	ContextSessionID = "sessionID"
+)
+
+// lastSeenInterval - как часто обновлять last_seen_at сессии
+const lastSeenInterval = time.Minute
+
+// passwordChangePaths - маршруты, доступные пока пользователь не сменил пароль
+var passwordChangePaths = map[string]bool{
+	"/api/me":       true,
+	"/api/password": true,
+	"/api/logout":   true,
+}
+
+// AuthRequired проверяет токен из заголовка Authorization ("Bearer <token>")
+// и кладёт данные пользователя в контекст. Других способов передать токен
+// нет (см. extractToken).
+// Роль и статус берутся из БД, поэтому отключение пользователя или смена
+// роли действуют сразу, не дожидаясь истечения токена.
+func AuthRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := extractToken(c)
+		if tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Требуется авторизация"})
+			return
+		}
+
+		claims, err := auth.ParseToken(tokenString)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Недействительный или просроченный токен"})
+			return
+		}
+
+		db := database.GetDB()
+
+		// Сессия должна существовать и не быть отозванной (выход, отзыв админом)
+		var session models.Session
+		if err := db.First(&session, "id = ? AND revoked_at IS NULL", claims.ID).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Сессия завершена, войдите заново"})
+			return
+		}
+		if time.Since(session.LastSeenAt) > lastSeenInterval {
+			db.Model(&session).Update("last_seen_at", time.Now())
+		}
+
+		var user models.User
+		if err := db.First(&user, "id = ?", claims.UserID).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Пользователь не найден или отключён"})
+			return
+		}
+
+		if user.MustChangePassword && !passwordChangePaths[c.FullPath()] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success":              false,
+				"error":                "Необходимо сменить пароль",
+				"must_change_password": true,
+			})
+			return
+		}
+
+		c.Set(ContextUserID, user.ID)
+		c.Set(ContextUsername, user.Username)
+		c.Set(ContextRole, user.Role)
+		c.Set(ContextSessionID, session.ID)
+		c.Next()
+	}
+}
+
+// extractToken - токен только из заголовка "Authorization: Bearer <token>".
+// В query (?token=) токен не принимается: он попадал бы в логи gin, прокси
+// и историю браузера. Файлы UI скачивает через fetch с заголовком.
+func extractToken(c *gin.Context) string {
+	header := strings.TrimSpace(c.GetHeader("Authorization"))
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// RequirePermission пропускает запрос, только если роли пользователя
+// разрешено действие perm (см. auth.rolePermissions). Должен стоять после AuthRequired.
+func RequirePermission(perm auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString(ContextRole)
+		if !auth.HasPermission(role, perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"success": false, "error": "Недостаточно прав"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -6,16 +6,28 @@ import (
 	"gorm.io/gorm"
 )
 
-// Location represents a warehouse location/shelf/bin
+// Location represents a warehouse location/shelf/bin.
+// Локации образуют дерево склад → зона → ряд → секция → полка → ячейка
+// (см. пакет locations); товары хранятся только в ячейках (kind = bin).
 type Location struct {
-	ID          string    `gorm:"primaryKey" json:"id"`
-	Code        string    `gorm:"uniqueIndex" json:"code"`
-	Description string    `json:"description"`
-	Row         string    `json:"row"`
-	Section     string    `json:"section"`
-	Shelf       string    `json:"shelf"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          string `gorm:"primaryKey" json:"id"`
+	Code        string `gorm:"uniqueIndex" json:"code"`
+	Description string `json:"description"`
+	Kind        string `gorm:"index" json:"kind"`                // warehouse, zone, row, section, shelf, bin
+	ParentID    string `gorm:"index" json:"parent_id,omitempty"` // пусто - корень (склад)
+	Path        string `gorm:"index" json:"path"`                // /<id склада>/.../<id>/ - для выборки поддерева
+	Row         string `json:"row"`                              // коды ряда/секции/полки для этикеток и --genqr
+	Section     string `json:"section"`
+	Shelf       string `json:"shelf"`
+	// Ограничения (0 / пусто - нет) действуют на локацию вместе с вложенными
+	MaxUnits          int            `json:"max_units"`                   // единиц товара
+	MaxWeight         float64        `json:"max_weight"`                  // кг
+	MaxVolume         float64        `json:"max_volume"`                  // л
+	AllowedCategories string         `json:"allowed_categories"`          // через запятую
+	Children          []Location     `gorm:"-" json:"children,omitempty"` // только в ответе ?tree=true
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"` // выведенная из работы локация
 }
 
 // Item represents an inventory item
@@ -27,28 +39,96 @@ type Item struct {
 	Quantity       int            `json:"quantity"`
 	Unit           string         `json:"unit"`     // шт, кг, м, л
 	Category       string         `json:"category"` // категория/тип
+	Weight         float64        `json:"weight"`   // кг на единицу (для ограничений локаций)
+	Volume         float64        `json:"volume"`   // л на единицу
 	PartNumber     string         `json:"part_number"`
 	BatchNumber    string         `json:"batch_number"`
-	BatchQuantity  int            `json:"batch_quantity"`   // количество привезённого
-	BatchArrivedAt *time.Time     `json:"batch_arrived_at"` // время приезда партии
-	InvoicePhoto   string         `json:"invoice_photo"`    // путь к фото накладной
-	LocationID     string         `gorm:"index" json:"location_id"`
+	BatchQuantity  int            `json:"batch_quantity"`           // количество привезённого
+	BatchArrivedAt *time.Time     `json:"batch_arrived_at"`         // время приезда партии
+	BatchExpiresAt *time.Time     `json:"batch_expires_at"`         // срок годности партии (GS1 AI 17)
+	InvoicePhoto   string         `json:"invoice_photo"`            // путь к фото накладной
+	LocationID     string         `gorm:"index" json:"location_id"` // основная локация
 	Location       *Location      `gorm:"foreignKey:LocationID;references:ID" json:"location,omitempty"`
+	Balances       []StockBalance `gorm:"foreignKey:ItemID" json:"balances,omitempty"` // остатки по локациям
+	Barcodes       []ItemBarcode  `gorm:"foreignKey:ItemID" json:"barcodes,omitempty"` // заводские штрихкоды
+	Version        int            `gorm:"not null;default:1" json:"version"`           // растёт при каждом изменении остатков/карточки
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// ItemBarcode - заводской штрихкод товара (EAN/UPC, GS1, код поставщика).
+// EAN-8/UPC-A/EAN-13 и GTIN из GS1 хранятся как GTIN-14 (gs1.Key),
+// поэтому товар находится по любому представлению одного кода.
+type ItemBarcode struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	ItemID    string    `gorm:"index;not null" json:"item_id"`
+	Code      string    `gorm:"uniqueIndex;not null" json:"code"`
+	Kind      string    `json:"kind"` // gtin или other
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// StockBalance - остаток товара на одной локации. Один SKU может лежать на
+// нескольких полках; Item.Quantity равен сумме остатков по всем локациям.
+// Пустой LocationID - товар принят, но не размещён.
+type StockBalance struct {
+	ID         int64     `gorm:"primaryKey" json:"id"`
+	ItemID     string    `gorm:"uniqueIndex:idx_stock_item_location" json:"item_id"`
+	LocationID string    `gorm:"uniqueIndex:idx_stock_item_location;index" json:"location_id"`
+	Location   *Location `gorm:"foreignKey:LocationID;references:ID" json:"location,omitempty"`
+	Quantity   int       `json:"quantity"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (StockBalance) TableName() string {
+	return "stock_balances"
+}
+
 // User represents a warehouse operator/admin
 type User struct {
-	ID           string         `gorm:"primaryKey" json:"id"`
-	Username     string         `gorm:"uniqueIndex" json:"username"`
-	Email        string         `gorm:"uniqueIndex" json:"email"`
-	PasswordHash string         `json:"-"`
-	Role         string         `json:"role"` // admin, operator
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID           string `gorm:"primaryKey" json:"id"`
+	Username     string `gorm:"uniqueIndex" json:"username"`
+	Email        string `gorm:"uniqueIndex" json:"email"`
+	PasswordHash string `json:"-"`
+	Role         string `json:"role"` // admin, operator, mechanic, storekeeper, engineer, manager, procurement, commercial
+	// MustChangePassword - пользователь обязан сменить пароль при следующем входе
+	// (новые учётки и сброс пароля администратором)
+	MustChangePassword bool           `json:"must_change_password"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"` // отключённый пользователь
+}
+
+// Session - выданный токен входа (устройство/вкладка). ID совпадает с jti в JWT,
+// поэтому отзыв сессии сразу делает токен недействительным.
+type Session struct {
+	ID         string     `gorm:"primaryKey" json:"id"`
+	UserID     string     `gorm:"index" json:"user_id"`
+	User       *User      `gorm:"foreignKey:UserID;references:ID" json:"user,omitempty"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	ExpiresAt  time.Time  `gorm:"index" json:"expires_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	RevokedBy  string     `json:"revoked_by,omitempty"` // кто завершил: сам пользователь или админ
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// AuthEvent - журнал входов, выходов и обновлений токена
+type AuthEvent struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	Event     string    `gorm:"index" json:"event"` // login_success, login_failure, login_blocked, logout, token_refresh, session_revoked
+	UserID    string    `gorm:"index" json:"user_id"`
+	Username  string    `json:"username"` // логин из запроса (в т.ч. несуществующий)
+	SessionID string    `json:"session_id,omitempty"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+func (AuthEvent) TableName() string {
+	return "auth_events"
 }
 
 // ItemMovement represents the audit log of item movements
@@ -60,6 +140,7 @@ type ItemMovement struct {
 	FromLocation   *Location `gorm:"foreignKey:FromLocationID;references:ID" json:"from_location,omitempty"`
 	ToLocationID   string    `gorm:"index" json:"to_location_id"`
 	ToLocation     *Location `gorm:"foreignKey:ToLocationID;references:ID" json:"to_location,omitempty"`
+	Quantity       int       `json:"quantity"` // сколько единиц перемещено
 	UserID         string    `gorm:"index" json:"user_id"`
 	User           *User     `gorm:"foreignKey:UserID;references:ID" json:"user,omitempty"`
 	Notes          string    `json:"notes"`
@@ -72,15 +153,18 @@ func (ItemMovement) TableName() string {
 }
 
 type SupplyRequest struct {
-	ID          string    `json:"id" gorm:"primaryKey"`
-	ItemID      string    `json:"item_id"`
-	ItemName    string    `json:"item_name"` // Добавь это поле
-	RequestedBy string    `json:"requested_by"`
-	Quantity    int       `json:"quantity"`
-	Reason      string    `json:"reason"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID     string `json:"id" gorm:"primaryKey"`
+	ItemID string `json:"item_id"`
+	// Заявка механика и её строка, для которых заказана недостача
+	WorkOrderID     string    `json:"work_order_id,omitempty" gorm:"index"`
+	WorkOrderItemID int64     `json:"work_order_item_id,omitempty" gorm:"index"`
+	ItemName        string    `json:"item_name"` // Добавь это поле
+	RequestedBy     string    `json:"requested_by"`
+	Quantity        int       `json:"quantity"`
+	Reason          string    `json:"reason"`
+	Status          string    `json:"status"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
 }
 
 type Supplier struct {
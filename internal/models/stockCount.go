@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// StockCount — сессия инвентаризации (пересчёта) по набору локаций.
+// Считаются все ячейки выбранных локаций; итоги проверяются и проводятся
+// корректировками остатков (StockAdjustment).
+type StockCount struct {
+	ID          string           `gorm:"primaryKey" json:"id"`
+	Name        string           `json:"name"`
+	LocationIDs string           `json:"location_ids"`        // выбранные локации через запятую
+	Blind       bool             `json:"blind"`               // слепой счёт: учётный остаток скрыт до завершения
+	Round       int              `json:"round"`               // 1 - первичный счёт, 2 и далее - пересчёт расхождений
+	Status      string           `gorm:"index" json:"status"` // counting, review, posted, cancelled
+	CreatedBy   string           `json:"created_by"`
+	PostedBy    string           `json:"posted_by,omitempty"`
+	PostedAt    *time.Time       `json:"posted_at,omitempty"`
+	Lines       []StockCountLine `gorm:"foreignKey:CountID" json:"lines,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// StockCountLine — товар в ячейке: учётный и посчитанный остаток
+type StockCountLine struct {
+	ID               int64      `gorm:"primaryKey" json:"id"`
+	CountID          string     `gorm:"uniqueIndex:idx_count_item_location" json:"count_id"`
+	ItemID           string     `gorm:"uniqueIndex:idx_count_item_location" json:"item_id"`
+	Item             *Item      `gorm:"foreignKey:ItemID;references:ID" json:"item,omitempty"`
+	LocationID       string     `gorm:"uniqueIndex:idx_count_item_location" json:"location_id"`
+	Location         *Location  `gorm:"foreignKey:LocationID;references:ID" json:"location,omitempty"`
+	ExpectedQuantity int        `json:"expected_quantity"`          // учётный остаток в ячейке на момент счёта
+	CountedQuantity  *int       `json:"counted_quantity"`           // nil - ещё не посчитан
+	PreviousCounted  *int       `json:"previous_counted,omitempty"` // результат предыдущего раунда
+	Round            int        `json:"round"`                      // раунд, в котором строка посчитана
+	Status           string     `json:"status"`                     // pending, counted, adjusted
+	CountedBy        string     `json:"counted_by,omitempty"`
+	CountedAt        *time.Time `json:"counted_at,omitempty"`
+}
+
+// StockAdjustment - корректировка остатка в ячейке по итогам инвентаризации.
+// Вместе с ItemMovement образует журнал остатков: перемещение не меняет общий
+// остаток товара, корректировка меняет.
+type StockAdjustment struct {
+	ID          int64     `gorm:"primaryKey" json:"id"`
+	ItemID      string    `gorm:"index" json:"item_id"`
+	Item        *Item     `gorm:"foreignKey:ItemID;references:ID" json:"item,omitempty"`
+	LocationID  string    `gorm:"index" json:"location_id"`
+	Location    *Location `gorm:"foreignKey:LocationID;references:ID" json:"location,omitempty"`
+	Quantity    int       `json:"quantity"` // + излишек, - недостача
+	Before      int       `json:"before"`   // остаток в ячейке до корректировки
+	After       int       `json:"after"`
+	Reason      string    `json:"reason"`
+	CountID     string    `gorm:"index" json:"count_id,omitempty"`
+	CountLineID int64     `json:"count_line_id,omitempty"`
+	UserID      string    `gorm:"index" json:"user_id"`
+	User        *User     `gorm:"foreignKey:UserID;references:ID" json:"user,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
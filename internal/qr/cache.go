@@ -0,0 +1,210 @@
+package qr
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Cache - хранилище готовых изображений QR. Ключ - хэш содержимого этикетки
+// и параметров рендеринга (см. ImageKey): смена ключа подписи или параметров
+// даёт новый ключ, а все изображения одной этикетки имеют общий префикс.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Put(key string, data []byte)
+	// DeletePrefix удаляет изображения с ключом, начинающимся с prefix
+	DeletePrefix(prefix string)
+}
+
+// Параметры кэша по умолчанию
+const (
+	DefaultCacheSize = 512 // изображений в памяти
+	DefaultCacheDir  = "qrcodes/cache"
+)
+
+var (
+	cacheOnce    sync.Once
+	defaultCache Cache
+)
+
+// DefaultCache - кэш из окружения: QR_CACHE=memory (по умолчанию, LRU на
+// QR_CACHE_SIZE изображений) или disk (файлы в QR_CACHE_DIR)
+func DefaultCache() Cache {
+	cacheOnce.Do(func() {
+		switch os.Getenv("QR_CACHE") {
+		case "disk":
+			dir := os.Getenv("QR_CACHE_DIR")
+			if dir == "" {
+				dir = DefaultCacheDir
+			}
+			defaultCache = NewDiskCache(dir)
+		default:
+			size, err := strconv.Atoi(os.Getenv("QR_CACHE_SIZE"))
+			if err != nil || size <= 0 {
+				size = DefaultCacheSize
+			}
+			defaultCache = NewMemoryCache(size)
+		}
+	})
+	return defaultCache
+}
+
+// ImageKey - ключ кэша и ETag изображения: <хэш содержимого>-<хэш параметров>
+func ImageKey(t Type, id string, o RenderOptions) (string, error) {
+	content, err := Encode(t, id)
+	if err != nil {
+		return "", err
+	}
+	return contentHash(content) + "-" + shortHash(o.Key()+o.Ext()), nil
+}
+
+// Image - изображение QR объекта из кэша по умолчанию; при промахе рисуется
+// и сохраняется. Возвращает данные и ключ (для ETag).
+func Image(t Type, id string, o RenderOptions) ([]byte, string, error) {
+	key, err := ImageKey(t, id, o)
+	if err != nil {
+		return nil, "", err
+	}
+	cache := DefaultCache()
+	if data, ok := cache.Get(key); ok {
+		return data, key, nil
+	}
+
+	content, err := Encode(t, id)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := Render(content, o)
+	if err != nil {
+		return nil, "", err
+	}
+	cache.Put(key, data)
+	return data, key, nil
+}
+
+// Invalidate удаляет из кэша все изображения объекта (при удалении объекта)
+func Invalidate(t Type, id string) {
+	content, err := Encode(t, id)
+	if err != nil {
+		return
+	}
+	DefaultCache().DeletePrefix(contentHash(content) + "-")
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:12])
+}
+
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:6])
+}
+
+// memoryCache - LRU в памяти процесса
+type memoryCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // от недавно использованных к давно
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key  string
+	data []byte
+}
+
+// NewMemoryCache - LRU кэш на size изображений
+func NewMemoryCache(size int) Cache {
+	return &memoryCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (m *memoryCache) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	m.order.MoveToFront(el)
+	return el.Value.(*memoryEntry).data, true
+}
+
+func (m *memoryCache) Put(key string, data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.entries[key]; ok {
+		el.Value.(*memoryEntry).data = data
+		m.order.MoveToFront(el)
+		return
+	}
+	m.entries[key] = m.order.PushFront(&memoryEntry{key: key, data: data})
+	for m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+func (m *memoryCache) DeletePrefix(prefix string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, el := range m.entries {
+		if strings.HasPrefix(key, prefix) {
+			m.order.Remove(el)
+			delete(m.entries, key)
+		}
+	}
+}
+
+// diskCache - файлы <ключ> в каталоге; переживает перезапуск сервера
+type diskCache struct {
+	dir string
+}
+
+// NewDiskCache - кэш в каталоге dir
+func NewDiskCache(dir string) Cache {
+	return &diskCache{dir: dir}
+}
+
+func (d *diskCache) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(filepath.Join(d.dir, key))
+	return data, err == nil
+}
+
+func (d *diskCache) Put(key string, data []byte) {
+	if err := os.MkdirAll(d.dir, 0755); err != nil {
+		log.Printf("⚠️  Кэш QR: %v", err)
+		return
+	}
+	// Через временный файл, чтобы параллельный Get не прочитал половину
+	tmp, err := os.CreateTemp(d.dir, key+".tmp*")
+	if err != nil {
+		log.Printf("⚠️  Кэш QR: %v", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(d.dir, key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("⚠️  Кэш QR: %v", err)
+	}
+}
+
+func (d *diskCache) DeletePrefix(prefix string) {
+	matches, _ := filepath.Glob(filepath.Join(d.dir, prefix+"*"))
+	for _, m := range matches {
+		os.Remove(m)
+	}
+}
@@ -0,0 +1,33 @@
+package qr
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Dir - каталог выгрузки QR командой --genqr. Сервер его не раздаёт:
+// изображения в API берутся из кэша (Image).
+const Dir = "qrcodes"
+
+// filePrefixes - префикс имени файла для типа объекта
+var filePrefixes = map[Type]string{
+	TypeItem:      "item",
+	TypeLocation:  "loc",
+	TypeWorkOrder: "order",
+	TypeEquipment: "eq",
+}
+
+// FileName - имя PNG файла объекта с параметрами по умолчанию: item_<id>.png,
+// loc_<id>.png, order_<id>.png, eq_<id>.png
+func FileName(t Type, id string) string {
+	prefix, ok := filePrefixes[t]
+	if !ok {
+		prefix = strings.ToLower(string(t))
+	}
+	return prefix + "_" + id + ".png"
+}
+
+// FilePath - путь к файлу объекта в Dir
+func FilePath(t Type, id string) string {
+	return filepath.Join(Dir, FileName(t, id))
+}
@@ -0,0 +1,245 @@
+// Package qr - формат содержимого QR-этикеток склада.
+//
+// Текущий формат (версия 2):
+//
+//	WH:2:<TYPE>:<id>:<check>
+//
+// где <check> - "s" + усечённая HMAC-SHA256 подпись (ключ QR_SECRET, иначе
+// JWT_SECRET) либо "c" + CRC32, если ключ не задан. Подпись позволяет отличить
+// поддельную или перевыпущенную другим ключом этикетку от настоящей; при
+// заданном ключе этикетки с CRC32 отклоняются.
+//
+// Этикетки версии 1 ("ITEM:<id>", "LOC:<id>", "WO:<id>") по-прежнему
+// читаются, но помечаются как Legacy и отклоняются при QR_REQUIRE_SIGNATURE=true.
+//
+// Новые типы объектов добавляются через RegisterType.
+package qr
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Type - тип объекта на этикетке
+type Type string
+
+// Встроенные типы
+const (
+	TypeItem      Type = "ITEM"
+	TypeLocation  Type = "LOC"
+	TypeWorkOrder Type = "WO"
+	TypeEquipment Type = "EQ"
+)
+
+const (
+	// Version - текущая версия формата
+	Version = 2
+	// prefix - признак этикетки склада (формат версии 2+)
+	prefix = "WH"
+	// sigLen - длина подписи в символах base32 (50 бит)
+	sigLen = 10
+)
+
+var (
+	// ErrMalformed - содержимое не похоже на этикетку склада
+	ErrMalformed = errors.New("qr: malformed payload")
+	// ErrUnknownType - тип объекта не зарегистрирован
+	ErrUnknownType = errors.New("qr: unknown type")
+	// ErrUnsupportedVersion - этикетка более новой версии, чем поддерживает сервер
+	ErrUnsupportedVersion = errors.New("qr: unsupported version")
+	// ErrBadSignature - подпись или контрольная сумма не совпадает
+	ErrBadSignature = errors.New("qr: bad signature")
+	// ErrUnsigned - этикетка без подписи, а подпись обязательна
+	ErrUnsigned = errors.New("qr: unsigned payload")
+)
+
+// Payload - разобранное содержимое этикетки
+type Payload struct {
+	Version int    `json:"version"`
+	Type    Type   `json:"type"`
+	ID      string `json:"id"`
+	Signed  bool   `json:"signed"` // подпись HMAC проверена
+	Legacy  bool   `json:"legacy"` // формат версии 1 без контроля целостности
+}
+
+var (
+	typesMu sync.RWMutex
+	types   = map[Type]string{
+		TypeItem:      "товар",
+		TypeLocation:  "локация",
+		TypeWorkOrder: "заявка механика",
+		TypeEquipment: "техника",
+	}
+)
+
+// RegisterType добавляет тип объекта (код - латиница в верхнем регистре)
+func RegisterType(t Type, description string) {
+	typesMu.Lock()
+	defer typesMu.Unlock()
+	types[t] = description
+}
+
+// Types возвращает зарегистрированные типы с описаниями
+func Types() map[Type]string {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	out := make(map[Type]string, len(types))
+	for t, d := range types {
+		out[t] = d
+	}
+	return out
+}
+
+func knownType(t Type) bool {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	_, ok := types[t]
+	return ok
+}
+
+// Codec кодирует и проверяет этикетки с заданным ключом
+type Codec struct {
+	key              []byte
+	requireSignature bool
+}
+
+// NewCodec создаёт Codec. Пустой key - этикетки только с контрольной суммой.
+func NewCodec(key []byte, requireSignature bool) *Codec {
+	return &Codec{key: key, requireSignature: requireSignature}
+}
+
+var (
+	defaultOnce  sync.Once
+	defaultCodec *Codec
+)
+
+// Default - Codec из окружения: QR_SECRET (или JWT_SECRET) и QR_REQUIRE_SIGNATURE
+func Default() *Codec {
+	defaultOnce.Do(func() {
+		key := os.Getenv("QR_SECRET")
+		if key == "" {
+			key = os.Getenv("JWT_SECRET")
+		}
+		require, _ := strconv.ParseBool(os.Getenv("QR_REQUIRE_SIGNATURE"))
+		if key == "" {
+			log.Println("⚠️  QR_SECRET не задан, этикетки печатаются без подписи")
+		}
+		defaultCodec = NewCodec([]byte(key), require)
+	})
+	return defaultCodec
+}
+
+// Encode - содержимое этикетки для объекта (Codec по умолчанию)
+func Encode(t Type, id string) (string, error) {
+	return Default().Encode(t, id)
+}
+
+// Decode разбирает и проверяет этикетку (Codec по умолчанию)
+func Decode(content string) (Payload, error) {
+	return Default().Decode(content)
+}
+
+// Encode возвращает содержимое этикетки версии 2
+func (c *Codec) Encode(t Type, id string) (string, error) {
+	if !knownType(t) {
+		return "", fmt.Errorf("%w: %s", ErrUnknownType, t)
+	}
+	if id == "" || strings.ContainsAny(id, ":\r\n") {
+		return "", fmt.Errorf("%w: invalid id %q", ErrMalformed, id)
+	}
+	body := fmt.Sprintf("%s:%d:%s:%s", prefix, Version, t, id)
+	return body + ":" + c.check(body), nil
+}
+
+// Decode разбирает этикетку версии 2 или 1 и проверяет подпись
+func (c *Codec) Decode(content string) (Payload, error) {
+	content = strings.TrimSpace(content)
+
+	parts := strings.Split(content, ":")
+	if len(parts) == 2 {
+		return c.decodeLegacy(Type(parts[0]), parts[1])
+	}
+	if len(parts) != 5 || parts[0] != prefix {
+		return Payload{}, ErrMalformed
+	}
+
+	version, err := strconv.Atoi(parts[1])
+	if err != nil || version < 2 {
+		return Payload{}, ErrMalformed
+	}
+	if version > Version {
+		return Payload{}, ErrUnsupportedVersion
+	}
+
+	p := Payload{Version: version, Type: Type(parts[2]), ID: parts[3]}
+	if !knownType(p.Type) {
+		return p, fmt.Errorf("%w: %s", ErrUnknownType, p.Type)
+	}
+	if p.ID == "" {
+		return p, ErrMalformed
+	}
+
+	body := strings.Join(parts[:4], ":")
+	check := parts[4]
+	switch {
+	case strings.HasPrefix(check, "s"):
+		if len(c.key) == 0 || !hmac.Equal([]byte(check), []byte(c.sign(body))) {
+			return p, ErrBadSignature
+		}
+		p.Signed = true
+	case strings.HasPrefix(check, "c"):
+		// С ключом сервер печатает только подписанные этикетки, а CRC32
+		// может посчитать кто угодно - такая этикетка поддельная
+		if len(c.key) > 0 || check != checksum(body) {
+			return p, ErrBadSignature
+		}
+		if c.requireSignature {
+			return p, ErrUnsigned
+		}
+	default:
+		return p, ErrMalformed
+	}
+	return p, nil
+}
+
+func (c *Codec) decodeLegacy(t Type, id string) (Payload, error) {
+	p := Payload{Version: 1, Type: t, ID: id, Legacy: true}
+	if !knownType(t) {
+		return p, fmt.Errorf("%w: %s", ErrUnknownType, t)
+	}
+	if id == "" {
+		return p, ErrMalformed
+	}
+	if c.requireSignature {
+		return p, ErrUnsigned
+	}
+	return p, nil
+}
+
+// check - подпись, если задан ключ, иначе контрольная сумма
+func (c *Codec) check(body string) string {
+	if len(c.key) > 0 {
+		return c.sign(body)
+	}
+	return checksum(body)
+}
+
+func (c *Codec) sign(body string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(body))
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(mac.Sum(nil))
+	return "s" + enc[:sigLen]
+}
+
+func checksum(body string) string {
+	return fmt.Sprintf("c%08X", crc32.ChecksumIEEE([]byte(body)))
+}
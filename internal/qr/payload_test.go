@@ -0,0 +1,88 @@
+package qr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCodecEncode(t *testing.T) {
+	signed := NewCodec([]byte("secret"), false)
+	content, err := signed.Encode(TypeItem, "item1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(content, "WH:2:ITEM:item1:s") || len(content) != len("WH:2:ITEM:item1:")+1+sigLen {
+		t.Fatalf("Encode = %q", content)
+	}
+
+	plain := NewCodec(nil, false)
+	if content, _ := plain.Encode(TypeLocation, "loc1"); content != "WH:2:LOC:loc1:"+checksum("WH:2:LOC:loc1") {
+		t.Fatalf("Encode without key = %q", content)
+	}
+
+	for _, tc := range []struct {
+		t   Type
+		id  string
+		err error
+	}{
+		{"NOPE", "x", ErrUnknownType},
+		{TypeItem, "", ErrMalformed},
+		{TypeItem, "a:b", ErrMalformed},
+		{TypeItem, "a\nb", ErrMalformed},
+	} {
+		if _, err := signed.Encode(tc.t, tc.id); !errors.Is(err, tc.err) {
+			t.Errorf("Encode(%s, %q) error = %v, want %v", tc.t, tc.id, err, tc.err)
+		}
+	}
+}
+
+func TestCodecDecode(t *testing.T) {
+	key := []byte("secret")
+	signedCodec := NewCodec(key, false)
+	valid, _ := signedCodec.Encode(TypeItem, "item1")
+	otherKey, _ := NewCodec([]byte("other"), false).Encode(TypeItem, "item1")
+	crc, _ := NewCodec(nil, false).Encode(TypeItem, "item1")
+	tampered := strings.Replace(valid, ":item1:", ":item2:", 1)
+
+	cases := []struct {
+		name    string
+		codec   *Codec
+		content string
+		want    Payload
+		err     error
+	}{
+		{"valid signature", signedCodec, valid,
+			Payload{Version: 2, Type: TypeItem, ID: "item1", Signed: true}, nil},
+		{"surrounding whitespace", signedCodec, " " + valid + "\n",
+			Payload{Version: 2, Type: TypeItem, ID: "item1", Signed: true}, nil},
+		{"tampered id", signedCodec, tampered, Payload{}, ErrBadSignature},
+		{"wrong key", signedCodec, otherKey, Payload{}, ErrBadSignature},
+		{"checksum with key set", signedCodec, crc, Payload{}, ErrBadSignature},
+		{"signature without key", NewCodec(nil, false), valid, Payload{}, ErrBadSignature},
+		{"checksum without key", NewCodec(nil, false), crc,
+			Payload{Version: 2, Type: TypeItem, ID: "item1"}, nil},
+		{"bad checksum without key", NewCodec(nil, false), "WH:2:ITEM:item1:c00000000", Payload{}, ErrBadSignature},
+		{"checksum required signature", NewCodec(nil, true), crc, Payload{}, ErrUnsigned},
+		{"legacy", signedCodec, "ITEM:item1",
+			Payload{Version: 1, Type: TypeItem, ID: "item1", Legacy: true}, nil},
+		{"legacy required signature", NewCodec(key, true), "LOC:loc1", Payload{}, ErrUnsigned},
+		{"legacy unknown type", signedCodec, "FOO:1", Payload{}, ErrUnknownType},
+		{"legacy empty id", signedCodec, "ITEM:", Payload{}, ErrMalformed},
+		{"newer version", signedCodec, "WH:3:ITEM:item1:sAAAAAAAAAA", Payload{}, ErrUnsupportedVersion},
+		{"unknown type", signedCodec, "WH:2:FOO:item1:sAAAAAAAAAA", Payload{}, ErrUnknownType},
+		{"unknown check kind", signedCodec, "WH:2:ITEM:item1:x123", Payload{}, ErrMalformed},
+		{"foreign text", signedCodec, "hello world", Payload{}, ErrMalformed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := tc.codec.Decode(tc.content)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Decode(%q) error = %v, want %v", tc.content, err, tc.err)
+			}
+			if tc.err == nil && p != tc.want {
+				t.Fatalf("Decode(%q) = %+v, want %+v", tc.content, p, tc.want)
+			}
+		})
+	}
+}
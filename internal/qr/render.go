@@ -0,0 +1,266 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Форматы вывода
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+// Ограничения параметров рендеринга
+const (
+	DefaultSize   = 256
+	MinSize       = 64
+	MaxSize       = 2048
+	DefaultMargin = 4 // тихая зона по стандарту - 4 модуля
+	MaxMargin     = 16
+)
+
+// ErrInvalidOptions - недопустимые параметры рендеринга
+var ErrInvalidOptions = errors.New("qr: invalid render options")
+
+// RenderOptions - параметры изображения QR
+type RenderOptions struct {
+	Size       int    // ширина/высота в пикселях
+	Level      string // уровень коррекции: L, M, Q, H
+	Margin     int    // тихая зона в модулях
+	Foreground string // цвет модулей, hex RRGGBB
+	Background string // цвет фона, hex RRGGBB
+	Format     string // png или svg
+}
+
+// DefaultOptions - параметры этикеток по умолчанию
+func DefaultOptions() RenderOptions {
+	return RenderOptions{
+		Size:       DefaultSize,
+		Level:      "Q", // qrcode.High (25%), как у этикеток до настраиваемого рендеринга
+		Margin:     DefaultMargin,
+		Foreground: "000000",
+		Background: "FFFFFF",
+		Format:     FormatPNG,
+	}
+}
+
+// IsDefault - параметры совпадают с параметрами по умолчанию
+func (o RenderOptions) IsDefault() bool {
+	return o == DefaultOptions()
+}
+
+// Key - строка параметров для имени файла в кэше
+func (o RenderOptions) Key() string {
+	return fmt.Sprintf("%d_%s_%d_%s_%s", o.Size, o.Level, o.Margin, o.Foreground, o.Background)
+}
+
+// Ext - расширение файла
+func (o RenderOptions) Ext() string {
+	return "." + o.Format
+}
+
+// ContentType - MIME тип результата
+func (o RenderOptions) ContentType() string {
+	if o.Format == FormatSVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// ParseOptions читает параметры из query: size, level, margin, fg, bg, format.
+// Отсутствующие параметры берутся по умолчанию.
+func ParseOptions(q url.Values) (RenderOptions, error) {
+	o := DefaultOptions()
+
+	if v := q.Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < MinSize || n > MaxSize {
+			return o, fmt.Errorf("%w: size должен быть от %d до %d", ErrInvalidOptions, MinSize, MaxSize)
+		}
+		o.Size = n
+	}
+	if v := q.Get("level"); v != "" {
+		v = strings.ToUpper(v)
+		if _, ok := levels[v]; !ok {
+			return o, fmt.Errorf("%w: level должен быть L, M, Q или H", ErrInvalidOptions)
+		}
+		o.Level = v
+	}
+	if v := q.Get("margin"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > MaxMargin {
+			return o, fmt.Errorf("%w: margin должен быть от 0 до %d", ErrInvalidOptions, MaxMargin)
+		}
+		o.Margin = n
+	}
+	for _, c := range []struct {
+		param string
+		dst   *string
+	}{{"fg", &o.Foreground}, {"bg", &o.Background}} {
+		if v := q.Get(c.param); v != "" {
+			hex, ok := normalizeHex(v)
+			if !ok {
+				return o, fmt.Errorf("%w: %s должен быть цветом RRGGBB", ErrInvalidOptions, c.param)
+			}
+			*c.dst = hex
+		}
+	}
+	if v := q.Get("format"); v != "" {
+		v = strings.ToLower(v)
+		if v != FormatPNG && v != FormatSVG {
+			return o, fmt.Errorf("%w: format должен быть png или svg", ErrInvalidOptions)
+		}
+		o.Format = v
+	}
+	return o, nil
+}
+
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// Render рисует QR с содержимым content. Цвета и тихая зона рисуются здесь,
+// а не в go-qrcode, чтобы PNG и SVG выглядели одинаково.
+func Render(content string, o RenderOptions) ([]byte, error) {
+	modules, err := Modules(content, o.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Format == FormatSVG {
+		return renderSVG(modules, o), nil
+	}
+	return renderPNG(modules, o)
+}
+
+// Modules - матрица модулей QR без тихой зоны (true - тёмный модуль).
+// Нужна для форматов, которые рисуют QR сами (ZPL ^GFA).
+func Modules(content, level string) ([][]bool, error) {
+	l, ok := levels[level]
+	if !ok {
+		return nil, ErrInvalidOptions
+	}
+	code, err := qrcode.New(content, l)
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+	return code.Bitmap(), nil
+}
+
+// RenderFile кодирует объект и сохраняет изображение в файл
+func RenderFile(t Type, id, path string, o RenderOptions) (string, error) {
+	content, err := Encode(t, id)
+	if err != nil {
+		return "", err
+	}
+	data, err := Render(content, o)
+	if err != nil {
+		return content, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return content, err
+	}
+	return content, os.WriteFile(path, data, 0644)
+}
+
+// WriteFile сохраняет PNG этикетки с параметрами по умолчанию
+func WriteFile(t Type, id, path string) (string, error) {
+	return RenderFile(t, id, path, DefaultOptions())
+}
+
+// renderPNG масштабирует модули до целого числа пикселей и центрирует
+// код на холсте размером o.Size (остаток уходит в поля)
+func renderPNG(modules [][]bool, o RenderOptions) ([]byte, error) {
+	n := len(modules)
+	total := n + 2*o.Margin
+	scale := o.Size / total
+	if scale < 1 {
+		return nil, fmt.Errorf("%w: size %d меньше числа модулей %d", ErrInvalidOptions, o.Size, total)
+	}
+	offset := (o.Size - scale*n) / 2
+
+	fg, bg := parseHex(o.Foreground), parseHex(o.Background)
+	img := image.NewPaletted(image.Rect(0, 0, o.Size, o.Size), color.Palette{bg, fg})
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			x0, y0 := offset+x*scale, offset+y*scale
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(x0+dx, y0+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderSVG - векторный QR: одна горизонтальная полоса на серию тёмных модулей
+func renderSVG(modules [][]bool, o RenderOptions) []byte {
+	n := len(modules)
+	total := n + 2*o.Margin
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		o.Size, o.Size, total, total)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#%s"/>`, total, total, o.Background)
+	fmt.Fprintf(&b, `<path fill="#%s" d="`, o.Foreground)
+	for y, row := range modules {
+		for x := 0; x < n; {
+			if !row[x] {
+				x++
+				continue
+			}
+			start := x
+			for x < n && row[x] {
+				x++
+			}
+			fmt.Fprintf(&b, "M%d %dh%dv1h-%dz", start+o.Margin, y+o.Margin, x-start, x-start)
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return []byte(b.String())
+}
+
+// normalizeHex приводит "#abc", "abc", "#aabbcc" к "AABBCC"
+func normalizeHex(v string) (string, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "#")
+	if len(v) == 3 {
+		v = string([]byte{v[0], v[0], v[1], v[1], v[2], v[2]})
+	}
+	if len(v) != 6 {
+		return "", false
+	}
+	if _, err := strconv.ParseUint(v, 16, 32); err != nil {
+		return "", false
+	}
+	return strings.ToUpper(v), true
+}
+
+func parseHex(v string) color.RGBA {
+	n, _ := strconv.ParseUint(v, 16, 32)
+	return color.RGBA{R: uint8(n >> 16), G: uint8(n >> 8), B: uint8(n), A: 0xFF}
+}
@@ -1,70 +1,129 @@
 package routes
 
 import (
+	"QR-GENERATOR/internal/auth"
 	"QR-GENERATOR/internal/handlers"
+	"QR-GENERATOR/internal/middleware"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SetupRoutes(router *gin.Engine) {
+	// can - проверка права роли (таблица прав в auth/permissions.go)
+	can := middleware.RequirePermission
 
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok", "message": "🚀 Warehouse API is running"})
 	})
 
 	// Публичные
-	api := router.Group("/api")
+	public := router.Group("/api")
+	{
+		public.POST("/login", handlers.Login)
+	}
+
+	// Все остальные /api маршруты требуют токен
+	api := router.Group("/api", middleware.AuthRequired())
 	{
-		api.POST("/login", handlers.Login)
 		api.GET("/me", handlers.CurrentUser)
-		api.GET("/item/:id", handlers.GetItem)
-		api.GET("/item/:id/history", handlers.GetItemHistory)
-		api.POST("/move", handlers.MoveItem)
+		api.POST("/password", handlers.ChangePassword)
+		api.POST("/logout", handlers.Logout)
+		api.POST("/refresh", handlers.RefreshToken)
+		api.GET("/item/:id", can(auth.PermStockRead), handlers.GetItem)
+		api.GET("/item/:id/history", can(auth.PermStockRead), handlers.GetItemHistory)
+		api.GET("/location", can(auth.PermStockRead), handlers.GetLocation)
+		api.GET("/location/:id", can(auth.PermStockRead), handlers.GetLocation)
+		api.GET("/equipment/:id", can(auth.PermOrdersRead), handlers.GetEquipmentCard)
+		api.POST("/move", can(auth.PermStockMove), handlers.MoveItem)
+		api.GET("/putaway/suggest", can(auth.PermStockRead), handlers.PutawaySuggest)
+		api.POST("/qr/decode", can(auth.PermStockRead), handlers.DecodeQR)
+		api.POST("/scan/decode", can(auth.PermStockRead), handlers.ScanDecode)
+		api.POST("/barcode/parse", handlers.ParseBarcode)
 	}
 
 	// Админ
-	admin := router.Group("/api/admin")
+	admin := router.Group("/api/admin", middleware.AuthRequired())
 	{
-		admin.POST("/item", handlers.AdminCreateItem)
-		admin.GET("/items", handlers.AdminGetItems)
-		admin.PUT("/item/:id", handlers.AdminUpdateItem)
-		admin.DELETE("/item/:id", handlers.AdminDeleteItem)
-		admin.GET("/item/:id/qr", handlers.AdminGetItemQR)
-		admin.POST("/item/:id/photo", handlers.AdminUploadInvoicePhoto)
-		admin.GET("/locations", handlers.AdminGetLocations)
-		admin.POST("/location", handlers.AdminCreateLocation)
-		admin.GET("/location/:id/qr", handlers.AdminGetLocationQR)
-		admin.GET("/categories", handlers.AdminGetCategories)
-		admin.GET("/equipment", handlers.AdminGetEquipment)
-		admin.POST("/equipment", handlers.AdminCreateEquipment)
-		admin.PUT("/equipment/:id", handlers.AdminUpdateEquipment)
-		admin.DELETE("/equipment/:id", handlers.AdminDeleteEquipment)
-		admin.GET("/equipment/types", handlers.AdminGetEquipmentTypes)
+		admin.POST("/item", can(auth.PermCatalogWrite), handlers.AdminCreateItem)
+		admin.GET("/items", can(auth.PermCatalogRead), handlers.AdminGetItems)
+		admin.GET("/items/qr.zip", can(auth.PermCatalogRead), handlers.AdminGetItemsQRZip)
+		admin.PUT("/item/:id", can(auth.PermCatalogWrite), handlers.AdminUpdateItem)
+		admin.DELETE("/item/:id", can(auth.PermCatalogWrite), handlers.AdminDeleteItem)
+		admin.GET("/item/:id/qr", can(auth.PermCatalogRead), handlers.AdminGetItemQR)
+		admin.POST("/item/:id/photo", can(auth.PermCatalogWrite), handlers.AdminUploadInvoicePhoto)
+		admin.POST("/item/:id/barcodes", can(auth.PermCatalogWrite), handlers.AdminAddItemBarcode)
+		admin.DELETE("/item/:id/barcodes/:barcode_id", can(auth.PermCatalogWrite), handlers.AdminDeleteItemBarcode)
+		admin.GET("/locations", can(auth.PermCatalogRead), handlers.AdminGetLocations)
+		admin.POST("/location", can(auth.PermCatalogWrite), handlers.AdminCreateLocation)
+		admin.POST("/location/:id/move", can(auth.PermCatalogWrite), handlers.AdminMoveLocation)
+		admin.PUT("/location/:id", can(auth.PermCatalogWrite), handlers.AdminUpdateLocation)
+		admin.DELETE("/location/:id", can(auth.PermCatalogWrite), handlers.AdminDeleteLocation)
+		admin.POST("/location/:id/restore", can(auth.PermCatalogWrite), handlers.AdminRestoreLocation)
+		admin.PUT("/location/:id/capacity", can(auth.PermCatalogWrite), handlers.AdminUpdateLocationCapacity)
+		admin.GET("/location/:id/qr", can(auth.PermCatalogRead), handlers.AdminGetLocationQR)
+		admin.GET("/labels", can(auth.PermCatalogRead), handlers.AdminGetLabels)
+		admin.GET("/labels/layouts", can(auth.PermCatalogRead), handlers.AdminGetLabelLayouts)
+		admin.POST("/labels/print", can(auth.PermCatalogWrite), handlers.AdminPrintLabels)
+		admin.GET("/categories", can(auth.PermCatalogRead), handlers.AdminGetCategories)
+		admin.GET("/equipment", can(auth.PermCatalogRead), handlers.AdminGetEquipment)
+		admin.POST("/equipment", can(auth.PermCatalogWrite), handlers.AdminCreateEquipment)
+		admin.PUT("/equipment/:id", can(auth.PermCatalogWrite), handlers.AdminUpdateEquipment)
+		admin.DELETE("/equipment/:id", can(auth.PermCatalogWrite), handlers.AdminDeleteEquipment)
+		admin.GET("/equipment/types", can(auth.PermCatalogRead), handlers.AdminGetEquipmentTypes)
+		admin.GET("/equipment/:id/qr", can(auth.PermCatalogRead), handlers.AdminGetEquipmentQR)
+
+		admin.GET("/users", can(auth.PermUsersManage), handlers.AdminGetUsers)
+		admin.POST("/users", can(auth.PermUsersManage), handlers.AdminCreateUser)
+		admin.PUT("/users/:id", can(auth.PermUsersManage), handlers.AdminUpdateUser)
+		admin.DELETE("/users/:id", can(auth.PermUsersManage), handlers.AdminDisableUser)
+		admin.POST("/users/:id/restore", can(auth.PermUsersManage), handlers.AdminRestoreUser)
+		admin.POST("/users/:id/reset-password", can(auth.PermUsersManage), handlers.AdminResetUserPassword)
+		admin.POST("/users/:id/unlock", can(auth.PermUsersManage), handlers.AdminUnlockUser)
+		admin.DELETE("/users/:id/sessions", can(auth.PermUsersManage), handlers.AdminRevokeUserSessions)
+		admin.GET("/sessions", can(auth.PermUsersManage), handlers.AdminGetSessions)
+		admin.DELETE("/sessions/:id", can(auth.PermUsersManage), handlers.AdminRevokeSession)
+		admin.GET("/auth-events", can(auth.PermUsersManage), handlers.AdminGetAuthEvents)
 	}
 
 	// Механик
-	mechanic := router.Group("/api/mechanic")
+	mechanic := router.Group("/api/mechanic", middleware.AuthRequired())
+	{
+		mechanic.POST("/order", can(auth.PermOrdersCreate), handlers.CreateWorkOrder)
+		mechanic.GET("/orders", can(auth.PermOrdersRead), handlers.GetMyOrders)
+		mechanic.GET("/order/:id", can(auth.PermOrdersRead), handlers.GetWorkOrder)
+		mechanic.PUT("/order/:id/status", can(auth.PermOrdersManage), handlers.UpdateOrderStatus)
+		mechanic.POST("/order/:id/qr", can(auth.PermOrdersManage), handlers.GenerateOrderQR)
+		mechanic.GET("/order/:id/qr", can(auth.PermOrdersRead), handlers.GetOrderQR)
+		mechanic.GET("/order/:id/label", can(auth.PermOrdersManage), handlers.GetOrderLabel)
+		mechanic.POST("/order/:id/issue", can(auth.PermOrdersManage), handlers.IssueOrder)
+	}
+
+	supply := router.Group("/api/supply", middleware.AuthRequired())
 	{
-		mechanic.POST("/order", handlers.CreateWorkOrder)
-		mechanic.GET("/orders", handlers.GetMyOrders)
-		mechanic.GET("/order/:id", handlers.GetWorkOrder)
-		mechanic.PUT("/order/:id/status", handlers.UpdateOrderStatus)
-		mechanic.POST("/order/:id/qr", handlers.GenerateOrderQR)
-		mechanic.POST("/order/:id/issue", handlers.IssueOrder)
+		supply.POST("/request", can(auth.PermSupplyRequest), handlers.CreateSupplyRequest)
+		supply.POST("/:id/approve-engineer", can(auth.PermSupplyApproveEngineer), handlers.ApproveByEngineer)
+		supply.POST("/:id/approve-manager", can(auth.PermSupplyApproveManager), handlers.ApproveByManager)
+		supply.POST("/:id/assign", can(auth.PermSupplyAssign), handlers.AssignProcurement)
+		supply.POST("/:id/select-supplier", can(auth.PermSupplySelectSupplier), handlers.SelectSupplier)
+		supply.POST("/:id/approve-commercial", can(auth.PermSupplyApproveCommercial), handlers.ApproveByCommercial)
+		supply.POST("/:id/receive", can(auth.PermSupplyReceive), handlers.ReceiveSupply)
+		supply.POST("/:id/reject-commercial", can(auth.PermSupplyRejectCommercial), handlers.RejectByCommercial)
+		supply.GET("/requests", can(auth.PermSupplyRead), handlers.GetSupplyRequests)
 	}
 
-	supply := router.Group("/api/supply")
+	// Инвентаризация: счёт на сканере, проверка и проведение корректировок
+	counts := router.Group("/api/counts", middleware.AuthRequired())
 	{
-		supply.POST("/request", handlers.CreateSupplyRequest)
-		supply.POST("/:id/approve-engineer", handlers.ApproveByEngineer)
-		supply.POST("/:id/approve-manager", handlers.ApproveByManager)
-		supply.POST("/:id/assign", handlers.AssignProcurement)
-		supply.POST("/:id/select-supplier", handlers.SelectSupplier)
-		supply.POST("/:id/approve-commercial", handlers.ApproveByCommercial)
-		supply.POST("/:id/receive", handlers.ReceiveSupply)
-		supply.POST("/:id/reject-commercial", handlers.RejectByCommercial)
-		supply.GET("/requests", handlers.GetSupplyRequests)
+		counts.POST("", can(auth.PermCountManage), handlers.StartCount)
+		counts.GET("", can(auth.PermCountRecord), handlers.GetCounts)
+		counts.GET("/:id", can(auth.PermCountRecord), handlers.GetCount)
+		counts.POST("/:id/lines", can(auth.PermCountRecord), handlers.RecordCount)
+		counts.POST("/:id/finish", can(auth.PermCountManage), handlers.FinishCount)
+		counts.GET("/:id/variances", can(auth.PermCountManage), handlers.GetCountVariances)
+		counts.POST("/:id/recount", can(auth.PermCountManage), handlers.RecountCount)
+		counts.POST("/:id/post", can(auth.PermCountManage), handlers.PostCount)
+		counts.POST("/:id/cancel", can(auth.PermCountManage), handlers.CancelCount)
 	}
 
 	// Статика
@@ -74,7 +133,6 @@ func SetupRoutes(router *gin.Engine) {
 	router.StaticFile("/supply", "./static/supply.html")
 	router.Static("/css", "./static/css")
 	router.Static("/js", "./static/js")
-	router.Static("/qrcodes", "./qrcodes")
 	router.Static("/invoices", "./static/invoices")
 
 	router.NoRoute(func(c *gin.Context) {
@@ -0,0 +1,168 @@
+// Package scan - распознавание QR и штрихкодов на фотографиях (gozxing).
+// Нужен устройствам, камера которых не справляется с jsQR в браузере,
+// и для массовой загрузки фото этикеток.
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // форматы для image.Decode
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+
+	"github.com/makiuchi-d/gozxing"
+	multiqr "github.com/makiuchi-d/gozxing/multi/qrcode"
+	"github.com/makiuchi-d/gozxing/oned"
+)
+
+// Форматы кодов
+const (
+	FormatQR      = "qr"
+	FormatEAN13   = "ean13"
+	FormatEAN8    = "ean8"
+	FormatUPCA    = "upca"
+	FormatCode128 = "code128"
+)
+
+// Ограничения изображения
+const (
+	// MaxPixels - защита от огромных картинок (распаковка в память)
+	MaxPixels = 50_000_000
+	// maxSide - длинная сторона после уменьшения; больше не помогает распознаванию,
+	// но сильно замедляет его
+	maxSide = 2000
+)
+
+var (
+	// ErrNotFound - на изображении нет читаемых кодов
+	ErrNotFound = errors.New("scan: no barcode found")
+	// ErrImage - файл не является изображением JPEG/PNG/GIF или слишком велик
+	ErrImage = errors.New("scan: unsupported or too large image")
+	// ErrImageShape - изображение слишком вытянуто: после уменьшения длинной
+	// стороны до maxSide короткая становится нулевой
+	ErrImageShape = errors.New("scan: image aspect ratio too extreme")
+)
+
+// Code - распознанный код
+type Code struct {
+	Format string `json:"format"`
+	Text   string `json:"text"`
+}
+
+var formats = map[gozxing.BarcodeFormat]string{
+	gozxing.BarcodeFormat_QR_CODE:  FormatQR,
+	gozxing.BarcodeFormat_EAN_13:   FormatEAN13,
+	gozxing.BarcodeFormat_EAN_8:    FormatEAN8,
+	gozxing.BarcodeFormat_UPC_A:    FormatUPCA,
+	gozxing.BarcodeFormat_CODE_128: FormatCode128,
+}
+
+// DecodeImage читает изображение и распознаёт коды на нём
+func DecodeImage(r io.ReadSeeker) ([]Code, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImage, err)
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d", ErrImage, cfg.Width, cfg.Height)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImage, err)
+	}
+	return Decode(img)
+}
+
+// Decode ищет на изображении все QR и по одному штрихкоду EAN/UPC и Code 128.
+// Повторы одного и того же кода убираются.
+func Decode(img image.Image) ([]Code, error) {
+	gray, err := grayscale(img, maxSide)
+	if err != nil {
+		return nil, err
+	}
+	bmp, err := gozxing.NewBinaryBitmap(gozxing.NewHybridBinarizer(gozxing.NewLuminanceSourceFromImage(gray)))
+	if err != nil {
+		return nil, err
+	}
+
+	var codes []Code
+	seen := make(map[Code]bool)
+	add := func(r *gozxing.Result) {
+		format, ok := formats[r.GetBarcodeFormat()]
+		if !ok || r.GetText() == "" {
+			return
+		}
+		code := Code{Format: format, Text: r.GetText()}
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+
+	// QR: несколько этикеток на одном фото
+	if results, err := multiqr.NewQRCodeMultiReader().DecodeMultiple(bmp, hints); err == nil {
+		for _, r := range results {
+			add(r)
+		}
+	}
+
+	// Линейные коды: TRY_HARDER также пробует изображение, повёрнутое на 90°
+	upcHints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+		gozxing.DecodeHintType_POSSIBLE_FORMATS: []gozxing.BarcodeFormat{
+			gozxing.BarcodeFormat_EAN_13, gozxing.BarcodeFormat_EAN_8, gozxing.BarcodeFormat_UPC_A,
+		},
+	}
+	for _, reader := range []struct {
+		r     gozxing.Reader
+		hints map[gozxing.DecodeHintType]interface{}
+	}{
+		{oned.NewMultiFormatUPCEANReader(upcHints), upcHints},
+		{oned.NewCode128Reader(), hints},
+	} {
+		if r, err := reader.r.Decode(bmp, reader.hints); err == nil {
+			add(r)
+		}
+	}
+
+	if len(codes) == 0 {
+		return nil, ErrNotFound
+	}
+	return codes, nil
+}
+
+// grayscale переводит изображение в оттенки серого, уменьшая длинную
+// сторону до max (усреднение по блокам, чтобы не терять тонкие штрихи)
+func grayscale(img image.Image, max int) (*image.Gray, error) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	scale := 1
+	for w/scale > max || h/scale > max {
+		scale++
+	}
+	if w/scale == 0 || h/scale == 0 {
+		return nil, fmt.Errorf("%w: %dx%d", ErrImageShape, w, h)
+	}
+
+	out := image.NewGray(image.Rect(0, 0, w/scale, h/scale))
+	for y := 0; y < h/scale; y++ {
+		for x := 0; x < w/scale; x++ {
+			var sum int
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					sum += int(color.GrayModel.Convert(img.At(b.Min.X+x*scale+dx, b.Min.Y+y*scale+dy)).(color.Gray).Y)
+				}
+			}
+			out.SetGray(x, y, color.Gray{Y: uint8(sum / (scale * scale))})
+		}
+	}
+	return out, nil
+}
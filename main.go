@@ -1,21 +1,27 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"QR-GENERATOR/internal/auth"
 	"QR-GENERATOR/internal/database"
+	"QR-GENERATOR/internal/inventory"
+	"QR-GENERATOR/internal/labels"
 	"QR-GENERATOR/internal/models"
+	"QR-GENERATOR/internal/qr"
 	"QR-GENERATOR/internal/routes"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-	qrcode "github.com/skip2/go-qrcode"
 	"gorm.io/gorm"
 )
 
@@ -28,8 +34,18 @@ func main() {
 
 	// Парси флагов командной строки
 	seedFlag := flag.Bool("seed", false, "Заполнить БД тестовыми данными")
-	genqrFlag := flag.Bool("genqr", false, "Сгенерировать QR коды")
+	genqrFlag := flag.Bool("genqr", false, "Сгенерировать QR коды для товаров, локаций и техники из БД")
+	onlyFlag := flag.String("only", "", "Для --genqr: только item, location или equipment")
+	categoryFlag := flag.String("category", "", "Для --genqr: категория товаров")
+	rowFlag := flag.String("row", "", "Для --genqr: ряд локаций")
+	sinceFlag := flag.String("since", "", "Для --genqr: созданные с даты YYYY-MM-DD или за период (72h)")
+	zipFlag := flag.String("zip", "", "Для --genqr: собрать QR в ZIP архив")
 	serverFlag := flag.Bool("server", true, "Запустить API сервер (по умолчанию)")
+	labelsFlag := flag.String("labels", "", "Сформировать ZPL этикетки: item, location, order или equipment")
+	idsFlag := flag.String("ids", "", "ID объектов для --labels через запятую (пусто - все)")
+	layoutFlag := flag.String("layout", labels.DefaultZPLLayout, "Раскладка этикеток для --labels")
+	printerFlag := flag.String("printer", "", "Адрес принтера host[:9100] для --labels")
+	outFlag := flag.String("out", "", "Файл для ZPL (по умолчанию stdout)")
 	flag.Parse()
 
 	// Инициализируем подключение к БД
@@ -39,6 +55,14 @@ func main() {
 
 	log.Println("✓ Подключено к БД warehouse")
 
+	// Печать этикеток из командной строки - сервер не запускаем
+	if *labelsFlag != "" {
+		if err := printLabels(*labelsFlag, *idsFlag, *layoutFlag, *printerFlag, *outFlag); err != nil {
+			log.Fatalf("❌ Ошибка печати этикеток: %v", err)
+		}
+		return
+	}
+
 	// Заполняем БД тестовыми данными если флаг --seed
 	if *seedFlag {
 		seedDatabase()
@@ -46,7 +70,19 @@ func main() {
 
 	// Генерируем QR коды если флаг --genqr
 	if *genqrFlag {
-		generateTestQRCodes()
+		since, err := parseSince(*sinceFlag)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		switch *onlyFlag {
+		case "", "item", "location", "equipment":
+		default:
+			log.Fatalf("❌ --only: ожидается item, location или equipment")
+		}
+		filter := qrFilter{Only: *onlyFlag, Category: *categoryFlag, Row: *rowFlag, Since: since}
+		if err := generateQRCodes(filter, *zipFlag); err != nil {
+			log.Fatalf("❌ Ошибка генерирования QR: %v", err)
+		}
 	}
 
 	// Запускаем API сервер
@@ -59,12 +95,23 @@ func seedDatabase() {
 	db := database.GetDB()
 	log.Println("🌱 Заполнение БД тестовыми данными...")
 
-	// Создаём тестовые локации
+	// Создаём тестовые локации: склад и ячейки в нём
 	locations := []models.Location{
+		{
+			ID:          "wh1",
+			Code:        "WH-1",
+			Description: "Основной склад",
+			Kind:        "warehouse",
+			Path:        "/wh1/",
+			CreatedAt:   time.Now(),
+		},
 		{
 			ID:          "location1",
 			Code:        "LOC-A1",
 			Description: "Полка A - Ряд 1",
+			Kind:        "bin",
+			ParentID:    "wh1",
+			Path:        "/wh1/location1/",
 			Row:         "A",
 			Section:     "1",
 			Shelf:       "1",
@@ -74,6 +121,9 @@ func seedDatabase() {
 			ID:          "location2",
 			Code:        "LOC-A2",
 			Description: "Полка A - Ряд 2",
+			Kind:        "bin",
+			ParentID:    "wh1",
+			Path:        "/wh1/location2/",
 			Row:         "A",
 			Section:     "2",
 			Shelf:       "1",
@@ -83,6 +133,9 @@ func seedDatabase() {
 			ID:          "location3",
 			Code:        "LOC-B1",
 			Description: "Полка B - Ряд 1",
+			Kind:        "bin",
+			ParentID:    "wh1",
+			Path:        "/wh1/location3/",
 			Row:         "B",
 			Section:     "1",
 			Shelf:       "2",
@@ -143,62 +196,253 @@ func seedDatabase() {
 	}
 	log.Printf("✓ Создано %d товаров", len(items))
 
-	// Создаём тестового пользователя
-	user := models.User{
-		ID:           "user1",
-		Username:     "operator1",
-		Email:        "operator1@warehouse.local",
-		PasswordHash: "sha_hash_of_password123",
-		Role:         "operator",
-		CreatedAt:    time.Now(),
+	// Начальные остатки по локациям (весь остаток на location_id товара)
+	if err := inventory.BackfillBalances(db); err != nil {
+		log.Printf("❌ Ошибка при создании остатков: %v", err)
 	}
 
-	result := db.FirstOrCreate(&user, models.User{ID: user.ID})
-	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
-		log.Printf("❌ Ошибка при создании пользователя: %v", result.Error)
-	} else {
-		log.Println("✓ Создан тестовый пользователь (оператор)")
+	// Создаём тестовых пользователей (operator1 / password123)
+	// и администратора (admin / admin123, смена пароля при первом входе)
+	seedUsers := []struct {
+		user     models.User
+		password string
+	}{
+		{
+			user: models.User{
+				ID:        "user1",
+				Username:  "operator1",
+				Email:     "operator1@warehouse.local",
+				Role:      "operator",
+				CreatedAt: time.Now(),
+			},
+			password: "password123",
+		},
+		{
+			user: models.User{
+				ID:                 "admin1",
+				Username:           "admin",
+				Email:              "admin@warehouse.local",
+				Role:               "admin",
+				MustChangePassword: true,
+				CreatedAt:          time.Now(),
+			},
+			password: "admin123",
+		},
 	}
+
+	for _, su := range seedUsers {
+		passwordHash, err := auth.HashPassword(su.password)
+		if err != nil {
+			log.Printf("❌ Ошибка хеширования пароля: %v", err)
+			continue
+		}
+
+		user := su.user
+		user.PasswordHash = passwordHash
+
+		result := db.FirstOrCreate(&user, models.User{ID: user.ID})
+		if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+			log.Printf("❌ Ошибка при создании пользователя %s: %v", user.Username, result.Error)
+			continue
+		}
+
+		// Старые сиды хранили строку-заглушку вместо хеша — перезаписываем
+		if !auth.IsPasswordHash(user.PasswordHash) {
+			db.Model(&user).Update("password_hash", passwordHash)
+			log.Printf("✓ Исправлен хеш пароля пользователя %s", user.Username)
+		}
+		log.Printf("✓ Создан тестовый пользователь %s (%s)", user.Username, user.Role)
+	}
+}
+
+// qrFilter - фильтры --genqr
+type qrFilter struct {
+	Only     string    // item, location, equipment или пусто - все
+	Category string    // категория товара
+	Row      string    // ряд локации (для товаров - ряд основной локации)
+	Since    time.Time // созданные не раньше
 }
 
-func generateTestQRCodes() {
-	// Создаём папку для QR кодов
-	qrcodeDir := "qrcodes"
-	if err := os.MkdirAll(qrcodeDir, 0755); err != nil {
-		log.Fatalf("❌ Ошибка создания папки qrcodes: %v", err)
+// generateQRCodes генерирует QR для товаров, локаций и техники из БД в qrcodes/
+// (item_<id>.png, loc_<id>.png, eq_<id>.png) и при zipPath собирает их в архив
+func generateQRCodes(f qrFilter, zipPath string) error {
+	db := database.GetDB()
+	if err := os.MkdirAll(qr.Dir, 0755); err != nil {
+		return fmt.Errorf("создание папки %s: %w", qr.Dir, err)
 	}
 
-	log.Println("📱 Генерирование QR кодов с простым формат ID...")
+	log.Println("📱 Генерирование QR кодов...")
 
-	// Генерируем QR коды для товаров (формат: ITEM:item123)
-	for i := 1; i <= 3; i++ {
-		itemID := fmt.Sprintf("item%d", i)
-		content := fmt.Sprintf("ITEM:%s", itemID)
-		filePath := filepath.Join(qrcodeDir, fmt.Sprintf("item_%s.png", itemID))
+	type target struct {
+		t    qr.Type
+		id   string
+		name string
+	}
+	var targets []target
 
-		err := qrcode.WriteFile(content, qrcode.High, 256, filePath)
-		if err != nil {
-			log.Printf("❌ Ошибка генерирования QR для товара %s: %v", itemID, err)
-		} else {
-			log.Printf("✓ Сгенерирован QR: %s (содержание: %s)", filePath, content)
+	if f.Only == "" || f.Only == "item" {
+		query := db.Model(&models.Item{})
+		if f.Category != "" {
+			query = query.Where("items.category = ?", f.Category)
+		}
+		if f.Row != "" {
+			query = query.Joins("JOIN locations ON locations.id = items.location_id").Where(`locations."row" = ?`, f.Row)
+		}
+		if !f.Since.IsZero() {
+			query = query.Where("items.created_at >= ?", f.Since)
+		}
+		var items []models.Item
+		if err := query.Order("items.created_at").Find(&items).Error; err != nil {
+			return err
+		}
+		for _, item := range items {
+			targets = append(targets, target{qr.TypeItem, item.ID, item.SKU})
+		}
+	}
+
+	// Категория есть только у товаров - локации с ней не выбираются
+	if (f.Only == "" && f.Category == "") || f.Only == "location" {
+		query := db.Model(&models.Location{})
+		if f.Row != "" {
+			query = query.Where(`"row" = ?`, f.Row)
+		}
+		if !f.Since.IsZero() {
+			query = query.Where("created_at >= ?", f.Since)
+		}
+		var locs []models.Location
+		if err := query.Order("code").Find(&locs).Error; err != nil {
+			return err
+		}
+		for _, loc := range locs {
+			targets = append(targets, target{qr.TypeLocation, loc.ID, loc.Code})
+		}
+	}
+
+	// Наклейки на технику: без ряда и категории
+	if (f.Only == "" && f.Category == "" && f.Row == "") || f.Only == "equipment" {
+		query := db.Model(&models.Equipment{})
+		if !f.Since.IsZero() {
+			query = query.Where("created_at >= ?", f.Since)
+		}
+		var list []models.Equipment
+		if err := query.Order("name").Find(&list).Error; err != nil {
+			return err
+		}
+		for _, eq := range list {
+			targets = append(targets, target{qr.TypeEquipment, eq.ID, eq.LicensePlate})
 		}
 	}
 
-	// Генерируем QR коды для локаций (формат: LOC:location123)
-	for i := 1; i <= 3; i++ {
-		locID := fmt.Sprintf("location%d", i)
-		content := fmt.Sprintf("LOC:%s", locID)
-		filePath := filepath.Join(qrcodeDir, fmt.Sprintf("location_%s.png", locID))
+	var zw *zip.Writer
+	if zipPath != "" {
+		zf, err := os.Create(zipPath)
+		if err != nil {
+			return err
+		}
+		defer zf.Close()
+		zw = zip.NewWriter(zf)
+	}
 
-		err := qrcode.WriteFile(content, qrcode.High, 256, filePath)
+	var generated, failed int
+	for _, tg := range targets {
+		filePath := qr.FilePath(tg.t, tg.id)
+		content, err := qr.WriteFile(tg.t, tg.id, filePath)
 		if err != nil {
-			log.Printf("❌ Ошибка генерирования QR для локации %s: %v", locID, err)
-		} else {
-			log.Printf("✓ Сгенерирован QR: %s (содержание: %s)", filePath, content)
+			log.Printf("❌ Ошибка генерирования QR %s (%s): %v", tg.id, tg.name, err)
+			failed++
+			continue
+		}
+		log.Printf("✓ %s (%s, содержание: %s)", filePath, tg.name, content)
+		generated++
+
+		if zw != nil {
+			if err := addFileToZip(zw, filePath); err != nil {
+				return err
+			}
+		}
+	}
+
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return err
 		}
+		log.Printf("📦 Архив: %s", zipPath)
+	}
+	log.Printf("✓ Сгенерировано QR: %d, ошибок: %d (папка ./%s/)", generated, failed, qr.Dir)
+	return nil
+}
+
+func addFileToZip(zw *zip.Writer, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	w, err := zw.Create(filepath.Base(path))
+	if err != nil {
+		return err
 	}
+	_, err = w.Write(data)
+	return err
+}
 
-	log.Println("✓ Все QR коды сгенерированы в папке ./qrcodes/")
+// parseSince принимает дату 2006-01-02 или длительность назад (72h)
+func parseSince(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("--since: ожидается дата YYYY-MM-DD или длительность (72h): %s", v)
+	}
+	return time.Now().Add(-d), nil
+}
+
+// printLabels формирует ZPL этикетки и отправляет их на принтер (raw TCP)
+// или пишет в файл / stdout
+func printLabels(kind, ids, layoutName, printer, out string) error {
+	layout, err := labels.GetLayout(layoutName)
+	if err != nil {
+		return err
+	}
+
+	var idList []string
+	for _, id := range strings.Split(ids, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			idList = append(idList, id)
+		}
+	}
+	sheet, err := labels.Load(database.GetDB(), kind, idList, len(idList) == 0)
+	if err != nil {
+		return err
+	}
+	if len(sheet) == 0 {
+		return fmt.Errorf("ничего не найдено")
+	}
+
+	var buf bytes.Buffer
+	if err := labels.RenderZPL(&buf, layout, sheet, labels.DefaultZPLOptions()); err != nil {
+		return err
+	}
+
+	switch {
+	case printer != "":
+		if err := labels.SendRaw(printer, buf.Bytes()); err != nil {
+			return err
+		}
+		log.Printf("🖨  Отправлено этикеток: %d на %s", len(sheet), printer)
+	case out != "":
+		if err := os.WriteFile(out, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		log.Printf("✓ Этикеток: %d, сохранено в %s", len(sheet), out)
+	default:
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return nil
 }
 
 func startAPIServer() {
@@ -227,8 +471,9 @@ func startAPIServer() {
 
 	log.Printf("\n🚀 API сервер запущен на http://localhost:%s", port)
 	log.Printf("\n📱 Сканер доступен: http://localhost:%s", port)
-	log.Printf("\n📚 Документация API:", port)
-	log.Printf("   POST   /api/login         - Вход (username/password)")
+	log.Println("\n📚 Документация API:")
+	log.Printf("   POST   /api/login         - Вход (username/password), выдаёт JWT")
+	log.Printf("   GET    /api/me            - Текущий пользователь (Authorization: Bearer <token>)")
 	log.Printf("   GET    /api/item/:id      - Получить информацию о товаре")
 	log.Printf("   GET    /api/item/:id/history - История перемещений товара")
 	log.Printf("   POST   /api/move          - Переместить товар на новую локацию")
@@ -0,0 +1,115 @@
+// ============================================================================
+// AUTH HELPERS — общий токен сессии для всех страниц
+// ============================================================================
+
+const AUTH_TOKEN_KEY = 'wms_token';
+
+function saveToken(token) {
+    localStorage.setItem(AUTH_TOKEN_KEY, token);
+}
+
+function getToken() {
+    return localStorage.getItem(AUTH_TOKEN_KEY);
+}
+
+function clearToken() {
+    localStorage.removeItem(AUTH_TOKEN_KEY);
+}
+
+// logoutSession — завершает сессию на сервере и удаляет токен
+function logoutSession() {
+    if (getToken()) {
+        authFetch('/api/logout', { method: 'POST' }).catch(() => {});
+    }
+    clearToken();
+}
+
+// authFetch — fetch с заголовком Authorization: Bearer <token>
+async function authFetch(url, options = {}) {
+    const headers = Object.assign({}, options.headers || {});
+    const token = getToken();
+    if (token) {
+        headers['Authorization'] = 'Bearer ' + token;
+    }
+    const response = await fetch(url, Object.assign({}, options, { headers }));
+    if (response.status === 401) {
+        clearToken();
+    }
+    return response;
+}
+
+// authBlobURL — загружает файл с заголовком Authorization и возвращает
+// object URL для img src / a href. Токен не попадает в адрес и в логи.
+async function authBlobURL(url) {
+    const response = await authFetch(url);
+    if (!response.ok) {
+        let message = 'Ошибка загрузки (' + response.status + ')';
+        try { message = (await response.json()).error || message; } catch (e) {}
+        throw new Error(message);
+    }
+    return URL.createObjectURL(await response.blob());
+}
+
+// authDownload — скачивание файла (QR, ZIP, этикетки) с авторизацией.
+// Имя берётся из Content-Disposition, если filename не задан.
+async function authDownload(url, filename) {
+    try {
+        const response = await authFetch(url);
+        if (!response.ok) {
+            let message = 'Ошибка загрузки (' + response.status + ')';
+            try { message = (await response.json()).error || message; } catch (e) {}
+            throw new Error(message);
+        }
+        if (!filename) {
+            const m = /filename="?([^";]+)"?/.exec(response.headers.get('Content-Disposition') || '');
+            filename = m ? m[1] : 'download';
+        }
+        const href = URL.createObjectURL(await response.blob());
+        const a = document.createElement('a');
+        a.href = href;
+        a.download = filename;
+        document.body.appendChild(a);
+        a.click();
+        a.remove();
+        setTimeout(() => URL.revokeObjectURL(href), 60000);
+    } catch (e) {
+        alert('❌ ' + e.message);
+    }
+}
+
+// ensurePasswordChanged — если сервер требует смену пароля (первый вход или
+// сброс администратором), запрашивает новый пароль и меняет его через /api/password
+async function ensurePasswordChanged(loginData, oldPassword) {
+    if (!loginData.must_change_password) return true;
+
+    const newPassword = prompt('Необходимо сменить пароль. Новый пароль (минимум 8 символов):');
+    if (!newPassword) return false;
+
+    const response = await authFetch('/api/password', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ old_password: oldPassword, new_password: newPassword })
+    });
+    const data = await response.json();
+    if (!data.success) {
+        alert(data.error || 'Не удалось сменить пароль');
+        return false;
+    }
+    saveToken(data.token);
+    return true;
+}
+
+// decodeQR — проверка содержимого этикетки на сервере (формат, подпись, существование объекта).
+// Возвращает { success, payload: { type, id, ... }, label } или { success: false, error }
+async function decodeQR(content) {
+    try {
+        const response = await authFetch('/api/qr/decode', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ content })
+        });
+        return await response.json();
+    } catch (e) {
+        return { success: false, error: 'Сервер недоступен' };
+    }
+}
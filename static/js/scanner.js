@@ -8,6 +8,10 @@ const state = {
     itemInfo: null,
     cameraStream: null,
     isScanning: false,
+    decoding: false,      // идёт проверка этикетки на сервере
+    count: null,          // выбранная инвентаризация: сканы идут в счёт, а не в перемещение
+    countLocation: null,
+    countItem: null,
     recentMoves: []
 };
 
@@ -39,13 +43,20 @@ async function login() {
         const data = await response.json();
 
         if (data.success) {
-            state.token = data.token;
+            saveToken(data.token);
+            if (!(await ensurePasswordChanged(data, password))) {
+                clearToken();
+                showMessage(messageDiv, 'Требуется смена пароля', 'error');
+                return;
+            }
+            state.token = getToken();
             state.currentUser = data.username;
             
             // Переключаемся на сканер
             document.getElementById('authSection').style.display = 'none';
             document.getElementById('scannerSection').style.display = 'block';
             document.getElementById('currentUser').textContent = data.username;
+            loadCounts();
             
             showMessage(messageDiv, 'Успешная авторизация! ✓', 'success');
         } else {
@@ -58,6 +69,7 @@ async function login() {
 
 function logout() {
     state.token = null;
+    logoutSession();
     state.currentUser = null;
     state.scannedItem = null;
     state.scannedLocation = null;
@@ -151,24 +163,77 @@ function scanQRCode() {
 // ============================================================================
 
 async function handleQRScan(qrContent) {
-    updateStatus('✓ QR код обнаружен: ' + qrContent);
-    
-    // Парсим QR: ITEM:item123 или LOC:location7
-    if (qrContent.startsWith('ITEM:')) {
-        const itemId = qrContent.substring(5);
-        await handleItemScan(itemId);
-    } else if (qrContent.startsWith('LOC:')) {
-        const locationId = qrContent.substring(4);
-        await handleLocationScan(locationId);
+    if (state.decoding) return;
+    state.decoding = true;
+    updateStatus('✓ QR код обнаружен, проверка...');
+
+    // Формат и подпись этикетки проверяет сервер (/api/qr/decode)
+    try {
+        const data = await decodeQR(qrContent);
+        if (!data.success) {
+            updateStatus('❌ ' + (data.error || 'Неизвестный формат QR'));
+            return;
+        }
+        if (data.payload.legacy) {
+            updateStatus('⚠️ Этикетка старого формата без подписи — рекомендуется перепечатать');
+        }
+
+        await handleScanned(data.payload.type, data.payload.id);
+    } finally {
+        state.decoding = false;
+    }
+}
+
+async function handleScanned(type, id) {
+    if (state.count && (type === 'ITEM' || type === 'LOC')) {
+        await handleCountScan(type, id);
+    } else if (type === 'ITEM') {
+        await handleItemScan(id);
+    } else if (type === 'LOC') {
+        await handleLocationScan(id);
+    } else if (type === 'EQ') {
+        await handleEquipmentScan(id);
     } else {
-        updateStatus('❌ Неизвестный формат QR: ' + qrContent);
+        updateStatus('❌ Эта этикетка не относится к перемещению: ' + type);
+    }
+}
+
+// scanPhoto — распознавание сделанного фото на сервере (/api/scan/decode):
+// QR этикетки или заводской штрихкод (EAN-13, Code 128) товара
+async function scanPhoto(input) {
+    const file = input.files[0];
+    input.value = '';
+    if (!file || state.decoding) return;
+    state.decoding = true;
+    updateStatus('⏳ Распознавание фото...');
+
+    try {
+        const form = new FormData();
+        form.append('image', file);
+        const response = await authFetch(`${API_URL}/scan/decode`, { method: 'POST', body: form });
+        const data = await response.json();
+        if (!data.success) {
+            updateStatus('❌ ' + (data.error || 'Коды не найдены'));
+            return;
+        }
+
+        const found = data.codes.find(c => c.id);
+        if (!found) {
+            updateStatus('❌ ' + (data.codes[0].error || 'Объект не найден') + ': ' + data.codes[0].content);
+            return;
+        }
+        await handleScanned(found.type, found.id);
+    } catch (error) {
+        updateStatus('❌ Ошибка распознавания: ' + error.message);
+    } finally {
+        state.decoding = false;
     }
 }
 
 async function handleItemScan(itemId) {
     try {
         // Получаем информацию о товаре
-        const response = await fetch(`${API_URL}/item/${itemId}`);
+        const response = await authFetch(`${API_URL}/item/${itemId}`);
         const data = await response.json();
 
         if (data.success) {
@@ -179,9 +244,10 @@ async function handleItemScan(itemId) {
             document.getElementById('itemName').textContent = data.item.name;
             document.getElementById('itemSku').textContent = data.item.sku;
             document.getElementById('itemQuantity').textContent = data.item.quantity;
-            document.getElementById('itemLocation').textContent = data.item.location?.code || '—';
+            document.getElementById('itemLocation').textContent = formatBalances(data.item);
             
             document.getElementById('itemInfoContainer').style.display = 'block';
+            loadPutawaySuggestions(itemId);
             
             updateStatus('✓ Товар отсканирован: ' + data.item.name);
             
@@ -199,11 +265,89 @@ async function handleItemScan(itemId) {
     }
 }
 
+// handleEquipmentScan — наклейка на технике: карточка и история ремонтов
+// (какие детали уже ставили по прошлым заявкам)
+async function handleEquipmentScan(equipmentId) {
+    try {
+        const response = await authFetch(`${API_URL}/equipment/${encodeURIComponent(equipmentId)}`);
+        const data = await response.json();
+        if (!data.success) {
+            updateStatus('❌ ' + (data.error || 'Техника не найдена: ' + equipmentId));
+            return;
+        }
+
+        const eq = data.equipment;
+        document.getElementById('equipmentName').textContent = eq.name;
+        document.getElementById('equipmentPlate').textContent = eq.license_plate;
+        document.getElementById('equipmentType').textContent = [eq.type, eq.year || ''].filter(Boolean).join(', ') || '—';
+        document.getElementById('equipmentWarranty').textContent = eq.under_warranty && eq.warranty_until
+            ? 'до ' + new Date(eq.warranty_until).toLocaleDateString('ru-RU') : 'нет';
+
+        const parts = document.getElementById('equipmentParts');
+        parts.innerHTML = '';
+        if (!data.parts.length) parts.textContent = 'Детали ещё не выдавались';
+        data.parts.forEach(p => {
+            const row = document.createElement('div');
+            row.className = 'move-item';
+            const name = document.createElement('strong');
+            name.textContent = p.name + (p.part_number ? ' (' + p.part_number + ')' : '');
+            const info = document.createElement('div');
+            info.className = 'move-time';
+            info.textContent = `выдано ${p.issued} ${p.unit || 'шт'} · заявок: ${p.orders} · последняя ${new Date(p.last_issued).toLocaleDateString('ru-RU')}`;
+            row.append(name, info);
+            parts.appendChild(row);
+        });
+
+        const orders = document.getElementById('equipmentOrders');
+        orders.innerHTML = '';
+        if (!data.orders.length) orders.textContent = 'Заявок нет';
+        data.orders.forEach(o => {
+            const row = document.createElement('div');
+            row.className = 'move-item';
+            const time = document.createElement('div');
+            time.className = 'move-time';
+            time.textContent = new Date(o.created_at).toLocaleString('ru-RU') + ' · ' + o.id + ' · ' + o.status;
+            const details = document.createElement('div');
+            details.className = 'move-details';
+            details.textContent = [o.work_type, o.mechanic?.username, o.description].filter(Boolean).join(' · ');
+            row.append(time, details);
+            orders.appendChild(row);
+        });
+
+        document.getElementById('equipmentContainer').style.display = 'block';
+        updateStatus('✓ Техника: ' + eq.name + ' ' + eq.license_plate);
+    } catch (error) {
+        updateStatus('❌ Ошибка получения техники: ' + error.message);
+    }
+}
+
+// loadPutawaySuggestions — подсказка, в какие ячейки положить товар
+async function loadPutawaySuggestions(itemId) {
+    const el = document.getElementById('itemPutaway');
+    el.textContent = '…';
+    try {
+        const response = await authFetch(`${API_URL}/putaway/suggest?item_id=${encodeURIComponent(itemId)}&limit=3`);
+        const data = await response.json();
+        el.textContent = data.success && data.suggestions.length
+            ? data.suggestions.map(s => s.location.code).join(', ')
+            : '—';
+    } catch (error) {
+        el.textContent = '—';
+    }
+}
+
 async function handleLocationScan(locationId) {
+    const loc = await loadLocationContents(locationId);
+    // Полка, ряд или зона - только содержимое: товар кладётся в ячейку
+    if (loc && loc.kind !== 'bin') {
+        updateStatus('✓ ' + loc.code + ': содержимое показано ниже, для перемещения отсканируйте ячейку');
+        return;
+    }
+
     state.scannedLocation = locationId;
-    document.getElementById('scannedLocation').textContent = locationId;
+    document.getElementById('scannedLocation').textContent = loc ? loc.code : locationId;
     
-    updateStatus('✓ Локация отсканирована: ' + locationId);
+    updateStatus('✓ Локация отсканирована: ' + (loc ? loc.code : locationId));
     
     // Если уже есть товар - показываем форму подтверждения
     if (state.scannedItem) {
@@ -213,6 +357,211 @@ async function handleLocationScan(locationId) {
     }
 }
 
+// Содержимое локации (/api/location/:id): товары с остатками и последние
+// перемещения. Возвращает локацию или null, если загрузить не удалось.
+async function loadLocationContents(locationId) {
+    try {
+        const response = await authFetch(`${API_URL}/location/${encodeURIComponent(locationId)}`);
+        const data = await response.json();
+        if (!data.success) {
+            updateStatus('❌ ' + (data.error || 'Локация не найдена: ' + locationId));
+            return null;
+        }
+
+        const loc = data.location;
+        document.getElementById('locationCode').textContent =
+            data.ancestors.map(a => a.code).concat(loc.code).join(' / ');
+        document.getElementById('locationDescription').textContent = loc.description || '—';
+        document.getElementById('locationTotal').textContent = data.total;
+
+        const stock = document.getElementById('locationStock');
+        stock.innerHTML = '';
+        if (!data.stock.length) stock.textContent = 'Локация пуста';
+        data.stock.forEach(s => {
+            const row = document.createElement('div');
+            row.className = 'move-item';
+            const name = document.createElement('strong');
+            name.textContent = s.name + ' (' + s.sku + ')';
+            const info = document.createElement('div');
+            info.className = 'move-time';
+            info.textContent = `${s.quantity} ${s.unit || 'шт'}` + (s.location_id !== loc.id ? ' · ' + s.location_code : '');
+            row.append(name, info);
+            stock.appendChild(row);
+        });
+
+        const moves = document.getElementById('locationMoves');
+        moves.innerHTML = '';
+        if (!data.movements.length) moves.textContent = 'Перемещений нет';
+        data.movements.forEach(m => {
+            const row = document.createElement('div');
+            row.className = 'move-item';
+            const time = document.createElement('div');
+            time.className = 'move-time';
+            time.textContent = new Date(m.moved_at).toLocaleString('ru-RU') + (m.user ? ' · ' + m.user.username : '');
+            const details = document.createElement('div');
+            details.className = 'move-details';
+            details.textContent = `${m.item?.name || m.item_id}: ${m.quantity} шт. ` +
+                `${m.from_location?.code || 'не размещено'} → ${m.to_location?.code || 'не размещено'}`;
+            row.append(time, details);
+            moves.appendChild(row);
+        });
+
+        document.getElementById('locationContainer').style.display = 'block';
+        return loc;
+    } catch (error) {
+        updateStatus('❌ Ошибка получения локации: ' + error.message);
+        return null;
+    }
+}
+
+// ============================================================================
+// STOCK COUNT
+// ============================================================================
+
+// Открытые инвентаризации; селектор виден только тем, кому разрешён счёт
+async function loadCounts() {
+    try {
+        const response = await authFetch(`${API_URL}/counts?status=counting`);
+        const data = await response.json();
+        if (!data.success) return;
+
+        const select = document.getElementById('countSelect');
+        select.length = 1;
+        data.counts.forEach(cnt => {
+            const option = document.createElement('option');
+            option.value = cnt.id;
+            option.textContent = '📋 ' + (cnt.name || cnt.id) + (cnt.round > 1 ? ' (пересчёт ' + cnt.round + ')' : '');
+            select.appendChild(option);
+        });
+        document.getElementById('countSelector').style.display = data.counts.length ? 'block' : 'none';
+    } catch (error) {
+        // Без права на счёт селектор не показывается
+    }
+}
+
+async function selectCount(countId) {
+    state.count = null;
+    state.countLocation = null;
+    state.countItem = null;
+    document.getElementById('countContainer').style.display = 'none';
+    if (!countId) {
+        updateStatus('Режим перемещения');
+        return;
+    }
+
+    const response = await authFetch(`${API_URL}/counts/${encodeURIComponent(countId)}`);
+    const data = await response.json();
+    if (!data.success) {
+        updateStatus('❌ ' + data.error);
+        return;
+    }
+    resetScan();
+    state.count = { id: data.count.id, blind: data.count.blind, lines: data.lines };
+    document.getElementById('countLocation').textContent = 'отсканируйте ячейку';
+    document.getElementById('countItem').textContent = 'отсканируйте товар';
+    document.getElementById('countExpectedRow').style.display = data.count.blind ? 'none' : 'block';
+    document.getElementById('countLines').innerHTML = '';
+    document.getElementById('countContainer').style.display = 'block';
+    updateStatus('📋 Инвентаризация ' + data.count.id + ': отсканируйте ячейку');
+}
+
+async function handleCountScan(type, id) {
+    if (type === 'LOC') {
+        const response = await authFetch(`${API_URL}/location/${encodeURIComponent(id)}`);
+        const data = await response.json();
+        if (!data.success) {
+            updateStatus('❌ ' + data.error);
+            return;
+        }
+        state.countLocation = data.location;
+        state.countItem = null;
+        document.getElementById('countLocation').textContent = data.location.code;
+        document.getElementById('countItem').textContent = 'отсканируйте товар';
+        renderCountLines();
+        updateStatus('✓ Ячейка ' + data.location.code + ': сканируйте товары');
+        return;
+    }
+
+    if (!state.countLocation) {
+        updateStatus('👉 Сначала отсканируйте ячейку');
+        return;
+    }
+    const response = await authFetch(`${API_URL}/item/${encodeURIComponent(id)}`);
+    const data = await response.json();
+    if (!data.success) {
+        updateStatus('❌ Товар не найден: ' + id);
+        return;
+    }
+    state.countItem = data.item;
+    document.getElementById('countItem').textContent = data.item.name + ' (' + data.item.sku + ')';
+    const line = countLine(data.item.id, state.countLocation.id);
+    document.getElementById('countExpected').textContent = line && line.expected_quantity !== undefined ? line.expected_quantity : '—';
+    const input = document.getElementById('countQuantity');
+    input.value = '';
+    input.focus();
+    updateStatus('👉 Введите посчитанное количество');
+}
+
+async function recordCount() {
+    const quantity = parseInt(document.getElementById('countQuantity').value, 10);
+    if (!state.count || !state.countLocation || !state.countItem || isNaN(quantity) || quantity < 0) {
+        updateStatus('👉 Отсканируйте ячейку и товар и введите количество');
+        return;
+    }
+
+    try {
+        const response = await authFetch(`${API_URL}/counts/${encodeURIComponent(state.count.id)}/lines`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({
+                location_id: state.countLocation.id,
+                item_id: state.countItem.id,
+                quantity: quantity
+            })
+        });
+        const data = await response.json();
+        if (!data.success) {
+            showMessage(document.getElementById('message'), '❌ ' + data.error, 'error');
+            return;
+        }
+
+        const lines = state.count.lines.filter(l => l.id !== data.line.id);
+        lines.push(data.line);
+        state.count.lines = lines;
+        state.countItem = null;
+        document.getElementById('countItem').textContent = 'отсканируйте товар';
+        document.getElementById('countQuantity').value = '';
+        renderCountLines();
+        updateStatus('✓ Записано: ' + quantity + ' шт. Сканируйте следующий товар');
+    } catch (error) {
+        showMessage(document.getElementById('message'), 'Ошибка: ' + error.message, 'error');
+    }
+}
+
+function countLine(itemId, locationId) {
+    return state.count.lines.find(l => l.item_id === itemId && l.location_id === locationId);
+}
+
+// Строки текущей ячейки: что посчитано и что ещё нет
+function renderCountLines() {
+    const list = document.getElementById('countLines');
+    list.innerHTML = '';
+    state.count.lines
+        .filter(l => l.location_id === state.countLocation.id)
+        .forEach(l => {
+            const row = document.createElement('div');
+            row.className = 'move-item';
+            const name = document.createElement('strong');
+            name.textContent = l.item?.name || l.item_id;
+            const info = document.createElement('div');
+            info.className = 'move-time';
+            info.textContent = (l.counted_quantity === null ? 'не посчитан' : 'посчитано ' + l.counted_quantity) +
+                (l.expected_quantity !== undefined ? ' · по учёту ' + l.expected_quantity : '');
+            row.append(name, info);
+            list.appendChild(row);
+        });
+}
+
 // ============================================================================
 // MODE SWITCHING
 // ============================================================================
@@ -262,9 +611,10 @@ async function confirmMove() {
     }
 
     const notes = document.getElementById('notes').value;
+    const quantity = parseInt(document.getElementById('moveQuantity').value, 10) || 0;
 
     try {
-        const response = await fetch(`${API_URL}/move`, {
+        const response = await authFetch(`${API_URL}/move`, {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
@@ -272,7 +622,8 @@ async function confirmMove() {
             body: JSON.stringify({
                 item_id: state.scannedItem,
                 to_location_id: state.scannedLocation,
-                user_id: 'user1', // В реальности из токена
+                quantity: quantity,
+                version: state.itemInfo?.version || 0,
                 notes: notes
             })
         });
@@ -283,15 +634,18 @@ async function confirmMove() {
             // Добавляем в историю
             const moveRecord = {
                 time: new Date().toLocaleTimeString('ru-RU'),
-                item: state.scannedItem,
+                item: `${state.scannedItem} × ${data.movement.quantity}`,
                 from: state.itemInfo.location?.code || '—',
                 to: state.scannedLocation,
                 notes: notes
             };
             state.recentMoves.push(moveRecord);
 
-            showMessage(document.getElementById('message'), 
-                '✓ Товар успешно перемещён!', 'success');
+            // CAPACITY_POLICY=warn: перемещение выполнено сверх ограничений локации
+            const warnings = (data.warnings || []).map(w => w.message).join('; ');
+            showMessage(document.getElementById('message'),
+                warnings ? '⚠️ Перемещено, но: ' + warnings : '✓ Товар успешно перемещён!',
+                warnings ? 'warning' : 'success');
             
             updateRecentMoves();
             resetScan();
@@ -313,8 +667,11 @@ function resetScan() {
     document.getElementById('scannedItem').textContent = '—';
     document.getElementById('scannedLocation').textContent = '—';
     document.getElementById('itemInfoContainer').style.display = 'none';
+    document.getElementById('equipmentContainer').style.display = 'none';
+    document.getElementById('locationContainer').style.display = 'none';
     document.getElementById('confirmContainer').style.display = 'none';
     document.getElementById('notes').value = '';
+    document.getElementById('moveQuantity').value = '';
     
     updateStatus('Готов к новому сканированию');
 }
@@ -362,6 +719,17 @@ function updateRecentMoves() {
     }
 }
 
+// Остатки товара по локациям: "LOC-A1: 40, LOC-B1: 10"
+function formatBalances(item) {
+    const balances = item.balances || [];
+    if (balances.length === 0) {
+        return item.location?.code || '—';
+    }
+    return balances
+        .map(b => `${b.location?.code || 'не размещено'}: ${b.quantity}`)
+        .join(', ');
+}
+
 function resetUI() {
     document.getElementById('scannedItem').textContent = '—';
     document.getElementById('scannedLocation').textContent = '—';